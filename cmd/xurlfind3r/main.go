@@ -2,19 +2,26 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hueristiq/hqgolog"
 	"github.com/hueristiq/hqgolog/formatter"
 	"github.com/hueristiq/hqgolog/levels"
 	"github.com/hueristiq/xurlfind3r/internal/configuration"
+	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/wayback"
 	"github.com/logrusorgru/aurora/v3"
 	"github.com/spf13/pflag"
 )
@@ -22,22 +29,78 @@ import (
 var (
 	au aurora.Aurora
 
-	configurationFilePath string
-	domains               []string
-	domainsListFilePath   string
-	includeSubdomains     bool
-	listSources           bool
-	sourcesToUse          []string
-	sourcesToExclude      []string
-	parseWaybackRobots    bool
-	parseWaybackSource    bool
-	filterPattern         string
-	matchPattern          string
-	monochrome            bool
-	output                string
-	outputDirectory       string
-	silent                bool
-	verbose               bool
+	configurationFilePath    string
+	domains                  []string
+	domainsListFilePath      string
+	includeSubdomains        bool
+	excludeHosts             []string
+	emitOutOfScope           bool
+	listSources              bool
+	sourcesToUse             []string
+	sourcesToExclude         []string
+	parseWaybackRobots       bool
+	parseWaybackSource       bool
+	waybackFrom              string
+	stateFile                string
+	waybackTo                string
+	waybackStatusCodes       []int
+	waybackRateLimit         int
+	rateLimitJitter          time.Duration
+	waybackSkipMetadata      bool
+	waybackMatchType         string
+	waybackCollapse          string
+	waybackLatestOnly        bool
+	waybackSnapshotsCollapse string
+	waybackBaseURL           string
+	waybackReplayModifier    string
+	skipSourceExtensions     []string
+	parseMIMETypes           []string
+	waybackAvailability      bool
+	commonCrawlIndexes       int
+	concurrency              int
+	timeout                  int
+	scrapeTimeout            time.Duration
+	sourceConcurrency        int
+	maxConsecutiveErrors     int
+	retries                  int
+	proxy                    string
+	insecureSkipVerify       bool
+	CABundle                 string
+	allowHTTPFallback        bool
+	userAgents               []string
+	randomUserAgent          bool
+	headers                  []string
+	maxBodySize              int
+	maxConnsPerHost          int
+	maxIdleConnsPerHost      int
+	cache                    bool
+	cacheDir                 string
+	cacheTTL                 time.Duration
+	refreshCache             bool
+	includeExtensions        []string
+	excludeExtensions        []string
+	filterPattern            string
+	matchPattern             string
+	sortQueryParams          bool
+	allowedSchemes           []string
+	uniquePaths              bool
+	knownURLsFilePath        string
+	subdomainsOnly           bool
+	countOnly                bool
+	summarizeByHost          bool
+	dedupMode                string
+	dedupCapacity            int
+	dedupFalsePositiveRate   float64
+	collapseParamValues      bool
+	maxResults               int
+	maxResultsPerSource      int
+	sortBy                   string
+	monochrome               bool
+	JSONOutput               bool
+	output                   string
+	outputDirectory          string
+	silent                   bool
+	verbose                  bool
 )
 
 func init() {
@@ -46,14 +109,70 @@ func init() {
 	pflag.StringSliceVarP(&domains, "domain", "d", []string{}, "")
 	pflag.StringVarP(&domainsListFilePath, "list", "l", "", "")
 	pflag.BoolVar(&includeSubdomains, "include-subdomains", false, "")
+	pflag.StringSliceVar(&excludeHosts, "exclude-hosts", []string{}, "")
+	pflag.BoolVar(&emitOutOfScope, "out-of-scope", false, "")
 	pflag.BoolVar(&listSources, "sources", false, "")
 	pflag.StringSliceVarP(&sourcesToUse, "use-sources", "u", []string{}, "")
 	pflag.StringSliceVarP(&sourcesToExclude, "exclude-sources", "e", []string{}, "")
 	pflag.BoolVar(&parseWaybackRobots, "parse-wayback-robots", false, "")
 	pflag.BoolVar(&parseWaybackSource, "parse-wayback-source", false, "")
+	pflag.StringVar(&waybackFrom, "wayback-from", "", "")
+	pflag.StringVar(&stateFile, "state-file", "", "")
+	pflag.StringVar(&waybackTo, "wayback-to", "", "")
+	pflag.IntSliceVar(&waybackStatusCodes, "wayback-status-codes", []int{}, "")
+	pflag.IntVar(&waybackRateLimit, "wayback-rate-limit", wayback.DefaultRateLimit, "")
+	pflag.DurationVar(&rateLimitJitter, "rate-limit-jitter", 0, "")
+	pflag.BoolVar(&waybackSkipMetadata, "wayback-skip-metadata", false, "")
+	pflag.StringVar(&waybackMatchType, "wayback-match-type", "", "")
+	pflag.StringVar(&waybackCollapse, "wayback-collapse", "", "")
+	pflag.BoolVar(&waybackLatestOnly, "wayback-latest-only", false, "")
+	pflag.StringVar(&waybackSnapshotsCollapse, "wayback-snapshots-collapse", "", "")
+	pflag.StringVar(&waybackBaseURL, "wayback-base-url", "", "")
+	pflag.StringVar(&waybackReplayModifier, "wayback-replay-modifier", "if_", "")
+	pflag.StringSliceVar(&skipSourceExtensions, "wayback-skip-extensions", wayback.DefaultSkipSourceExtensions, "")
+	pflag.StringSliceVar(&parseMIMETypes, "wayback-parse-mime-types", wayback.DefaultParseMIMETypes, "")
+	pflag.BoolVar(&waybackAvailability, "wayback-availability", false, "")
+	pflag.IntVar(&commonCrawlIndexes, "commoncrawl-indexes", 0, "")
+	pflag.IntVar(&concurrency, "concurrency", wayback.DefaultConcurrency, "")
+	pflag.IntVar(&timeout, "timeout", int(httpclient.DefaultOptions.Timeout.Seconds()), "")
+	pflag.DurationVar(&scrapeTimeout, "scrape-timeout", 0, "")
+	pflag.IntVar(&sourceConcurrency, "source-concurrency", 0, "")
+	pflag.IntVar(&maxConsecutiveErrors, "max-consecutive-errors", 0, "")
+	pflag.IntVar(&retries, "retries", httpclient.DefaultOptions.RetryMax, "")
+	pflag.StringVar(&proxy, "proxy", "", "")
+	pflag.BoolVar(&insecureSkipVerify, "insecure", false, "")
+	pflag.StringVar(&CABundle, "ca-bundle", "", "")
+	pflag.BoolVar(&allowHTTPFallback, "http-fallback", false, "")
+	pflag.StringArrayVar(&userAgents, "user-agent", []string{}, "")
+	pflag.BoolVar(&randomUserAgent, "random-user-agent", false, "")
+	pflag.StringArrayVarP(&headers, "header", "H", []string{}, "")
+	pflag.IntVar(&maxBodySize, "max-body-size", 0, "")
+	pflag.IntVar(&maxConnsPerHost, "max-conns-per-host", httpclient.DefaultMaxConnsPerHost, "")
+	pflag.IntVar(&maxIdleConnsPerHost, "max-idle-conns-per-host", 0, "")
+	pflag.BoolVar(&cache, "cache", false, "")
+	pflag.StringVar(&cacheDir, "cache-dir", "", "")
+	pflag.DurationVar(&cacheTTL, "cache-ttl", httpclient.DefaultCacheTTL, "")
+	pflag.BoolVar(&refreshCache, "refresh-cache", false, "")
+	pflag.StringSliceVar(&includeExtensions, "include-extensions", []string{}, "")
+	pflag.StringSliceVar(&excludeExtensions, "exclude-extensions", []string{}, "")
 	pflag.StringVarP(&filterPattern, "filter", "f", "", "")
 	pflag.StringVarP(&matchPattern, "match", "m", "", "")
+	pflag.BoolVar(&sortQueryParams, "sort-query-params", false, "")
+	pflag.StringSliceVar(&allowedSchemes, "allowed-schemes", sources.DefaultAllowedSchemes, "")
+	pflag.BoolVar(&uniquePaths, "unique-paths", false, "")
+	pflag.StringVar(&knownURLsFilePath, "known-urls", "", "")
+	pflag.BoolVar(&subdomainsOnly, "subdomains-only", false, "")
+	pflag.BoolVar(&countOnly, "count-only", false, "")
+	pflag.BoolVar(&summarizeByHost, "summary", false, "")
+	pflag.StringVar(&dedupMode, "dedup-mode", sources.DedupModeExact, "")
+	pflag.IntVar(&dedupCapacity, "dedup-capacity", sources.DefaultDedupCapacity, "")
+	pflag.Float64Var(&dedupFalsePositiveRate, "dedup-fp-rate", sources.DefaultDedupFalsePositiveRate, "")
+	pflag.BoolVar(&collapseParamValues, "collapse-param-values", false, "")
+	pflag.IntVar(&maxResults, "max-results", 0, "")
+	pflag.IntVar(&maxResultsPerSource, "max-results-per-source", 0, "")
+	pflag.StringVar(&sortBy, "sort-by", sources.SortByNone, "")
 	pflag.BoolVar(&monochrome, "no-color", false, "")
+	pflag.BoolVar(&JSONOutput, "json", false, "")
 	pflag.StringVarP(&output, "output", "o", "", "")
 	pflag.StringVarP(&outputDirectory, "outputDirectory", "O", "", "")
 	pflag.BoolVarP(&silent, "silent", "s", false, "")
@@ -71,7 +190,7 @@ func init() {
 		h += fmt.Sprintf(" -c, --configuration string          configuration file (default: %s)\n", defaultConfigurationFilePath)
 
 		h += "\nINPUT:\n"
-		h += " -d, --domain string[]               target domain\n"
+		h += " -d, --domain string[]               target domain, or *.<domain> with its subdomains, e.g. *.gov\n"
 		h += " -l, --list string                   target domains' list file path\n"
 
 		h += "\n   TIP: For multiple input domains use comma(,) separated value with `-d`,\n"
@@ -79,6 +198,8 @@ func init() {
 
 		h += "\nSCOPE:\n"
 		h += "     --include-subdomains bool       match subdomain's URLs\n"
+		h += "     --exclude-hosts string[]        comma(,) separated hosts to exclude, wildcards (*.example.com) allowed\n"
+		h += "     --out-of-scope bool             also output the URLs found out of scope, e.g. third-party ones\n"
 
 		h += "\nSOURCES:\n"
 		h += "     --sources bool                  list supported sources\n"
@@ -86,13 +207,69 @@ func init() {
 		h += " -e, --exclude-sources string[]      comma(,) separated sources to exclude\n"
 		h += "     --parse-wayback-robots bool     with wayback, parse robots.txt snapshots\n"
 		h += "     --parse-wayback-source bool     with wayback, parse source code snapshots\n"
+		h += "     --wayback-from string           with wayback, archived from timestamp (YYYYMMDD[hhmmss])\n"
+		h += "     --state-file string             with wayback, file to save, and list from, the latest timestamp listed\n"
+		h += "     --wayback-to string             with wayback, archived to timestamp (YYYYMMDD[hhmmss])\n"
+		h += "     --wayback-status-codes int[]    with wayback, archived status codes to match (-<code> excludes)\n"
+		h += fmt.Sprintf("     --wayback-rate-limit int        with wayback, maximum requests per minute (default: %d)\n", wayback.DefaultRateLimit)
+		h += fmt.Sprintf("     --rate-limit-jitter duration    maximum random delay added to rate limited requests, negative disables (default: %s)\n", sources.DefaultRateLimitJitter)
+		h += "     --wayback-skip-metadata bool    with wayback, list URLs without capture metadata\n"
+		h += "     --wayback-match-type string     with wayback, CDX match type (exact, prefix, host or domain)\n"
+		h += "     --wayback-collapse string       with wayback, URLs CDX collapse (urlkey, a field, e.g. original, or none: many more URLs) (default: urlkey)\n"
+		h += "     --wayback-latest-only bool      with wayback, list only the latest capture of each URL\n"
+		h += "     --wayback-snapshots-collapse string with wayback source, snapshots CDX collapse (digest, urlkey, timestamp:N or none) (default: digest)\n"
+		h += "     --wayback-skip-extensions string[] with wayback, comma(,) separated extensions not parsed (default: media)\n"
+		h += "     --wayback-parse-mime-types string[] with wayback source, comma(,) separated MIME types parsed (default: text-like)\n"
+		h += "     --wayback-availability bool     with wayback, parse only the closest snapshot of each URL for source\n"
+		h += fmt.Sprintf("     --wayback-base-url string       with wayback, CDX and replay server base URL (default: %s)\n", wayback.DefaultBaseURL)
+		h += "     --wayback-replay-modifier string with wayback source, snapshots replay, if_ (links rewritten, unwrapped) or id_ (raw) (default: if_)\n"
+		h += "     --commoncrawl-indexes int       with commoncrawl, number of most recent indexes to query\n"
+
+		h += "\nOPTIMIZATION:\n"
+		h += fmt.Sprintf("     --concurrency int               number of snapshots to parse concurrently (default: %d)\n", wayback.DefaultConcurrency)
+		h += fmt.Sprintf("     --timeout int                   request timeout in seconds (default: %d)\n", int(httpclient.DefaultOptions.Timeout.Seconds()))
+		h += "     --scrape-timeout duration       maximum time to spend finding URLs per domain, e.g. 60s\n"
+		h += "     --source-concurrency int        number of sources to run concurrently (default: all)\n"
+		h += "     --max-consecutive-errors int    stop finding URLs for a domain after this many errors in a row\n"
+		h += fmt.Sprintf("     --retries int                   number of retries on failed requests (default: %d)\n", httpclient.DefaultOptions.RetryMax)
+		h += "     --proxy string                  HTTP(S) or SOCKS5 proxy URL\n"
+		h += "     --insecure bool                 skip TLS certificate verification (reduces security)\n"
+		h += "     --ca-bundle string              PEM file of extra CA certificates to trust\n"
+		h += "     --http-fallback bool            retry failed HTTPS requests over plain HTTP (reduces security)\n"
+		h += "     --user-agent string[]           User-Agent to use, repeat to rotate through several\n"
+		h += "     --random-user-agent bool        rotate through common browser User-Agents\n"
+		h += " -H, --header string[]               header to send with every request, as `Name: value`, repeatable\n"
+		h += "     --max-body-size int             maximum response body size in MB, larger ones are skipped\n"
+		h += fmt.Sprintf("     --max-conns-per-host int        maximum connections per host (default: %d)\n", httpclient.DefaultMaxConnsPerHost)
+		h += "     --max-idle-conns-per-host int   idle connections kept alive per host, for reuse (default: none)\n"
+		h += "     --cache bool                    with wayback, cache CDX responses in memory\n"
+		h += "     --cache-dir string              with wayback, cache CDX responses on disk, in this directory\n"
+		h += fmt.Sprintf("     --cache-ttl duration            with wayback, how long cached responses stay fresh (default: %s)\n", httpclient.DefaultCacheTTL)
+		h += "     --refresh-cache bool            with wayback, refetch and replace cached responses\n"
+		h += fmt.Sprintf("     --dedup-mode string             URLs deduplication, %s or %s (approximate, fixed memory) (default: %s)\n", sources.DedupModeExact, sources.DedupModeBloom, sources.DedupModeExact)
+		h += fmt.Sprintf("     --dedup-capacity int            with bloom dedup mode, number of URLs to size for (default: %d)\n", sources.DefaultDedupCapacity)
+		h += fmt.Sprintf("     --dedup-fp-rate float           with bloom dedup mode, false positive rate (default: %v)\n", sources.DefaultDedupFalsePositiveRate)
 
 		h += "\nFILTER & MATCH:\n"
+		h += "     --include-extensions string[]   comma(,) separated extensions of URLs to match\n"
+		h += "     --exclude-extensions string[]   comma(,) separated extensions of URLs to filter\n"
 		h += " -f, --filter string                 regex to filter URLs\n"
 		h += " -m, --match string                  regex to match URLs\n"
+		h += "     --sort-query-params bool        ignore query parameters order when deduplicating URLs\n"
+		h += fmt.Sprintf("     --allowed-schemes string[]      comma(,) separated schemes of URLs to output (default: %s)\n", strings.Join(sources.DefaultAllowedSchemes, ","))
+		h += "     --unique-paths bool             output one URL per path, whatever its query\n"
+		h += "     --known-urls string             file of already known URLs, e.g. a previous output, not to output again\n"
+		h += "     --subdomains-only bool          output the unique hosts of URLs, instead of URLs\n"
+		h += "     --summary bool                  output, once done, the number of URLs found per host\n"
+		h += "     --count-only bool               output the number of URLs found per source, instead of URLs\n"
+		h += "     --collapse-param-values bool    replace query parameters values with FUZZ, one URL per parameters set\n"
+		h += "     --max-results int               maximum number of URLs to find per domain\n"
+		h += "     --max-results-per-source int    maximum number of URLs to find per domain and source\n"
+		h += "     --sort-by string                emit URLs, once found, sorted by url or timestamp, or none (default: none)\n"
 
 		h += "\nOUTPUT:\n"
 		h += "     --no-color bool                 disable colored output\n"
+		h += "     --json bool                     output URLs as JSON lines (source, value and capture metadata)\n"
 		h += " -o, --output string                 output URLs file path\n"
 		h += " -O, --output-directory string       output URLs directory path\n"
 		h += " -s, --silent bool                   display output subdomains only\n"
@@ -138,6 +315,37 @@ func main() {
 		hqgolog.Fatal().Msg(err.Error())
 	}
 
+	// merge the API keys and options set in the environment, those set by
+	// flags taking precedence.
+	envConfiguration := &sources.Configuration{Keys: config.Keys}
+
+	envOptions := []struct {
+		flag       string
+		value, env *int
+	}{
+		{"wayback-rate-limit", &waybackRateLimit, &envConfiguration.WaybackRateLimit},
+		{"concurrency", &concurrency, &envConfiguration.Concurrency},
+		{"timeout", &timeout, &envConfiguration.Timeout},
+	}
+
+	for _, option := range envOptions {
+		if pflag.CommandLine.Changed(option.flag) {
+			*option.env = *option.value
+		}
+	}
+
+	if err = envConfiguration.LoadEnv(); err != nil {
+		hqgolog.Fatal().Msg(err.Error())
+	}
+
+	for _, option := range envOptions {
+		if *option.env != 0 {
+			*option.value = *option.env
+		}
+	}
+
+	config.Keys = envConfiguration.Keys
+
 	// if --sources: List suported sources & exit.
 	if listSources {
 		hqgolog.Print().Msg("")
@@ -212,6 +420,31 @@ func main() {
 		}
 	}
 
+	// load known URLs, not to output again, from file, before the output
+	// file, possibly the same, is appended to.
+	var knownURLs []string
+
+	if knownURLsFilePath != "" {
+		var file *os.File
+
+		file, err = os.Open(knownURLsFilePath)
+		if err != nil {
+			hqgolog.Error().Msg(err.Error())
+
+			return
+		}
+
+		knownURLs, err = sources.ReadKnownURLs(file)
+
+		file.Close()
+
+		if err != nil {
+			hqgolog.Error().Msg(err.Error())
+
+			return
+		}
+	}
+
 	// scrape and output URLs.
 	var consolidatedWriter *bufio.Writer
 
@@ -236,15 +469,87 @@ func main() {
 		mkdir(outputDirectory)
 	}
 
+	if randomUserAgent {
+		userAgents = append(userAgents, httpclient.BrowserUserAgents...)
+	}
+
+	requestHeaders := http.Header{}
+
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			hqgolog.Fatal().Msgf("invalid header %q, expected `Name: value`", header)
+		}
+
+		requestHeaders.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
 	options := &scraper.Options{
-		IncludeSubdomains:  includeSubdomains,
-		SourcesToUSe:       sourcesToUse,
-		SourcesToExclude:   sourcesToExclude,
-		Keys:               config.Keys,
-		ParseWaybackRobots: parseWaybackRobots,
-		ParseWaybackSource: parseWaybackSource,
-		FilterPattern:      filterPattern,
-		Matchattern:        matchPattern,
+		IncludeSubdomains:        includeSubdomains,
+		ExcludeHosts:             excludeHosts,
+		EmitOutOfScope:           emitOutOfScope,
+		SourcesToUSe:             sourcesToUse,
+		SourcesToExclude:         sourcesToExclude,
+		Keys:                     config.Keys,
+		ParseWaybackRobots:       parseWaybackRobots,
+		ParseWaybackSource:       parseWaybackSource,
+		WaybackFrom:              waybackFrom,
+		StateFile:                stateFile,
+		WaybackTo:                waybackTo,
+		WaybackStatusCodes:       waybackStatusCodes,
+		WaybackRateLimit:         waybackRateLimit,
+		RateLimitJitter:          rateLimitJitter,
+		WaybackSkipMetadata:      waybackSkipMetadata,
+		WaybackMatchType:         waybackMatchType,
+		WaybackCollapse:          waybackCollapse,
+		WaybackLatestOnly:        waybackLatestOnly,
+		WaybackSnapshotsCollapse: waybackSnapshotsCollapse,
+		WaybackBaseURL:           waybackBaseURL,
+		WaybackReplayModifier:    waybackReplayModifier,
+		SkipSourceExtensions:     skipSourceExtensions,
+		ParseMIMETypes:           parseMIMETypes,
+		WaybackAvailability:      waybackAvailability,
+		CommonCrawlIndexes:       commonCrawlIndexes,
+		Concurrency:              concurrency,
+		Timeout:                  timeout,
+		ScrapeTimeout:            scrapeTimeout,
+		SourceConcurrency:        sourceConcurrency,
+		MaxConsecutiveErrors:     maxConsecutiveErrors,
+		Retries:                  &retries,
+		Proxy:                    proxy,
+		InsecureSkipVerify:       insecureSkipVerify,
+		CABundle:                 CABundle,
+		AllowHTTPFallback:        allowHTTPFallback,
+		UserAgents:               userAgents,
+		Headers:                  requestHeaders,
+		MaxBodySize:              int64(maxBodySize) << 20,
+		MaxConnsPerHost:          maxConnsPerHost,
+		MaxIdleConnsPerHost:      maxIdleConnsPerHost,
+		Cache:                    cache,
+		CacheDir:                 cacheDir,
+		CacheTTL:                 cacheTTL,
+		RefreshCache:             refreshCache,
+		IncludeExtensions:        includeExtensions,
+		ExcludeExtensions:        excludeExtensions,
+		FilterPattern:            filterPattern,
+		Matchattern:              matchPattern,
+		SortQueryParams:          sortQueryParams,
+		AllowedSchemes:           allowedSchemes,
+		UniquePaths:              uniquePaths,
+		KnownURLs:                knownURLs,
+		SubdomainsOnly:           subdomainsOnly,
+		CountOnly:                countOnly,
+		DedupMode:                dedupMode,
+		DedupCapacity:            dedupCapacity,
+		DedupFalsePositiveRate:   dedupFalsePositiveRate,
+		CollapseParamValues:      collapseParamValues,
+		MaxResults:               maxResults,
+		MaxResultsPerSource:      maxResultsPerSource,
+		SortBy:                   sortBy,
+	}
+
+	if verbose {
+		options.Logger = logger{}
 	}
 
 	var spr *scraper.Finder
@@ -256,8 +561,25 @@ func main() {
 		return
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	for index := range domains {
-		domain := domains[index]
+		if ctx.Err() != nil {
+			break
+		}
+
+		domain, wildcard, err := sources.NormalizeDomainPattern(domains[index])
+		if err != nil {
+			hqgolog.Error().Msg(err.Error())
+
+			continue
+		}
+
+		// scraped as the pattern, for its subdomains to be included.
+		if wildcard {
+			domain = "*." + domain
+		}
 
 		if !silent {
 			hqgolog.Print().Msg("")
@@ -265,11 +587,17 @@ func main() {
 			hqgolog.Print().Msg("")
 		}
 
-		URLs := spr.Scrape(domain)
+		URLs := spr.Scrape(ctx, domain)
+
+		var summary *sources.HostSummary
+
+		if summarizeByHost {
+			summary = sources.NewHostSummary()
+		}
 
 		switch {
 		case output != "":
-			outputURLs(consolidatedWriter, URLs)
+			outputURLs(consolidatedWriter, URLs, summary)
 		case outputDirectory != "":
 			var domainFile *os.File
 
@@ -282,9 +610,17 @@ func main() {
 
 			domainWriter := bufio.NewWriter(domainFile)
 
-			outputURLs(domainWriter, URLs)
+			outputURLs(domainWriter, URLs, summary)
 		default:
-			outputURLs(nil, URLs)
+			outputURLs(nil, URLs, summary)
+		}
+
+		if summary != nil {
+			counts := summary.Counts()
+
+			for _, host := range summary.Hosts() {
+				hqgolog.Print().Msgf("%s: %d", host, counts[host])
+			}
 		}
 	}
 }
@@ -309,22 +645,44 @@ func mkdir(path string) {
 	}
 }
 
-func outputURLs(writer *bufio.Writer, URLs chan sources.Result) {
+// outputURLs outputs URLs, tallying them in summary, if not nil.
+func outputURLs(writer *bufio.Writer, URLs chan sources.Result, summary *sources.HostSummary) {
 	for URL := range URLs {
+		if summary != nil {
+			summary.Add(URL)
+		}
+
 		switch URL.Type {
 		case sources.Error:
-			if verbose {
+			if verbose || errors.Is(URL.Error, scraper.ErrTooManyConsecutiveErrors) {
 				hqgolog.Error().Msgf("%s: %s\n", URL.Source, URL.Error)
 			}
-		case sources.URL:
-			if verbose {
+		case sources.URL, sources.Count, sources.OutOfScope:
+			line := URL.Value
+
+			if URL.Type == sources.Count {
+				line = fmt.Sprintf("%s: %d", URL.Source, URL.Count)
+			}
+
+			if JSONOutput {
+				data, err := URL.JSON()
+				if err != nil {
+					hqgolog.Error().Msg(err.Error())
+
+					continue
+				}
+
+				line = string(data)
+			}
+
+			if verbose && !JSONOutput && URL.Type != sources.Count {
 				hqgolog.Print().Msgf("[%s] %s", au.BrightBlue(URL.Source), URL.Value)
 			} else {
-				hqgolog.Print().Msg(URL.Value)
+				hqgolog.Print().Msg(line)
 			}
 
 			if writer != nil {
-				fmt.Fprintln(writer, URL.Value)
+				fmt.Fprintln(writer, line)
 
 				if err := writer.Flush(); err != nil {
 					hqgolog.Fatal().Msg(err.Error())
@@ -333,3 +691,21 @@ func outputURLs(writer *bufio.Writer, URLs chan sources.Result) {
 		}
 	}
 }
+
+// logger logs the sources' traces with hqgolog. Errors are dropped, they are
+// reported as results already.
+type logger struct{}
+
+func (logger) Debug(format string, args ...interface{}) {
+	hqgolog.Debug().Msgf(format, args...)
+}
+
+func (logger) Info(format string, args ...interface{}) {
+	hqgolog.Info().Msgf(format, args...)
+}
+
+func (logger) Warn(format string, args ...interface{}) {
+	hqgolog.Warn().Msgf(format, args...)
+}
+
+func (logger) Error(string, ...interface{}) {}
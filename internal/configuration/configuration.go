@@ -87,10 +87,12 @@ func CreateUpdate(path string) (err error) {
 		Version: VERSION,
 		Sources: sources.List,
 		Keys: sources.Keys{
-			Bevigil: []string{},
-			GitHub:  []string{},
-			Intelx:  []string{},
-			URLScan: []string{},
+			Bevigil:    []string{},
+			GitHub:     []string{},
+			Intelx:     []string{},
+			Shodan:     []string{},
+			URLScan:    []string{},
+			VirusTotal: []string{},
 		},
 	}
 
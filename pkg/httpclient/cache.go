@@ -0,0 +1,278 @@
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hueristiq/hqgohttp/headers"
+	"github.com/hueristiq/hqgohttp/status"
+)
+
+const (
+	// DefaultCacheTTL is how long cached responses are fresh for when no TTL
+	// is specified.
+	DefaultCacheTTL = 24 * time.Hour
+	// DefaultCacheMaxMemorySize is the size of the bodies kept in memory
+	// when no maximum is specified.
+	DefaultCacheMaxMemorySize = 64 * 1024 * 1024
+)
+
+// Cache is a response cache keyed by request URL. Entries are kept in memory,
+// the least recently used evicted past MaxMemorySize, and, if the cache has a
+// directory, on disk so that they outlive the process. Entries older than the
+// TTL are refetched.
+type Cache struct {
+	// Directory, if set, is where entries are persisted.
+	Directory string
+	// TTL is how long entries are fresh for, DefaultCacheTTL if not set.
+	TTL time.Duration
+	// MaxMemorySize is the size, in bytes, of the bodies kept in memory,
+	// DefaultCacheMaxMemorySize if not set.
+	MaxMemorySize int64
+	// Refresh bypasses cached entries, refetching and replacing them.
+	Refresh bool
+
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	recency    *list.List
+	memorySize int64
+}
+
+// cacheItem is an entry kept in memory, an element of the cache's recency
+// list, most recently used first.
+type cacheItem struct {
+	URL   string
+	entry *cacheEntry
+}
+
+type cacheEntry struct {
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// NewCache returns a cache persisting entries to directory, if set, that
+// stay fresh for TTL.
+func NewCache(directory string, TTL time.Duration) (cache *Cache) {
+	cache = &Cache{
+		Directory: directory,
+		TTL:       TTL,
+		entries:   map[string]*list.Element{},
+		recency:   list.New(),
+	}
+
+	return
+}
+
+// Client returns a Client serving fresh responses from the cache, and caching
+// the successful responses of client otherwise.
+func (cache *Cache) Client(client Client) Client {
+	return &cachingClient{
+		cache:  cache,
+		client: client,
+	}
+}
+
+// Clear removes every entry from the cache, including the persisted ones.
+func (cache *Cache) Clear() (err error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.entries = map[string]*list.Element{}
+	cache.recency = list.New()
+	cache.memorySize = 0
+
+	if cache.Directory != "" {
+		err = os.RemoveAll(cache.Directory)
+	}
+
+	return
+}
+
+func (cache *Cache) ttl() time.Duration {
+	if cache.TTL > 0 {
+		return cache.TTL
+	}
+
+	return DefaultCacheTTL
+}
+
+func (cache *Cache) maxMemorySize() int64 {
+	if cache.MaxMemorySize > 0 {
+		return cache.MaxMemorySize
+	}
+
+	return DefaultCacheMaxMemorySize
+}
+
+func (cache *Cache) path(URL string) string {
+	sum := sha256.Sum256([]byte(URL))
+
+	return filepath.Join(cache.Directory, hex.EncodeToString(sum[:])+".json")
+}
+
+func (cache *Cache) get(URL string) (entry *cacheEntry, ok bool) {
+	cache.mutex.Lock()
+
+	element, ok := cache.entries[URL]
+	if ok {
+		cache.recency.MoveToFront(element)
+
+		entry = element.Value.(*cacheItem).entry
+	}
+
+	cache.mutex.Unlock()
+
+	if !ok && cache.Directory != "" {
+		data, err := os.ReadFile(cache.path(URL))
+		if err != nil {
+			return
+		}
+
+		entry = &cacheEntry{}
+
+		if err = json.Unmarshal(data, entry); err != nil {
+			return
+		}
+
+		ok = true
+	}
+
+	if ok && time.Since(entry.StoredAt) > cache.ttl() {
+		ok = false
+	}
+
+	return
+}
+
+func (cache *Cache) set(URL string, entry *cacheEntry) (err error) {
+	cache.remember(URL, entry)
+
+	if cache.Directory == "" {
+		return
+	}
+
+	if err = os.MkdirAll(cache.Directory, 0o755); err != nil {
+		return
+	}
+
+	var data []byte
+
+	data, err = json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	err = os.WriteFile(cache.path(URL), data, 0o644)
+
+	return
+}
+
+// remember keeps entry in memory, evicting the least recently used entries
+// past the maximum memory size. Entries larger than it aren't kept.
+func (cache *Cache) remember(URL string, entry *cacheEntry) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.entries[URL]; ok {
+		cache.forget(element)
+	}
+
+	size := int64(len(entry.Body))
+
+	if size > cache.maxMemorySize() {
+		return
+	}
+
+	cache.entries[URL] = cache.recency.PushFront(&cacheItem{URL: URL, entry: entry})
+	cache.memorySize += size
+
+	for cache.memorySize > cache.maxMemorySize() {
+		cache.forget(cache.recency.Back())
+	}
+}
+
+func (cache *Cache) forget(element *list.Element) {
+	item := cache.recency.Remove(element).(*cacheItem)
+
+	delete(cache.entries, item.URL)
+
+	cache.memorySize -= int64(len(item.entry.Body))
+}
+
+type cachingClient struct {
+	cache  *Cache
+	client Client
+}
+
+func (client *cachingClient) Get(ctx context.Context, URL string) (res *http.Response, err error) {
+	if !client.cache.Refresh {
+		if entry, ok := client.cache.get(URL); ok {
+			res = entry.response()
+
+			return
+		}
+	}
+
+	res, err = client.client.Get(ctx, URL)
+	if err != nil {
+		return
+	}
+
+	var body []byte
+
+	body, err = io.ReadAll(res.Body)
+
+	res.Body.Close()
+
+	if err != nil {
+		return
+	}
+
+	entry := &cacheEntry{
+		Header:   res.Header,
+		Body:     body,
+		StoredAt: time.Now(),
+	}
+
+	// a failure to cache the response shouldn't fail the request.
+	_ = client.cache.set(URL, entry)
+
+	res = entry.response()
+
+	return
+}
+
+func (entry *cacheEntry) response() (res *http.Response) {
+	header := entry.Header.Clone()
+
+	if header == nil {
+		header = http.Header{}
+	}
+
+	header.Set(headers.ContentLength, strconv.Itoa(len(entry.Body)))
+
+	res = &http.Response{
+		Status:        "200 OK",
+		StatusCode:    status.OK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+	}
+
+	return
+}
@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// countingClient serves bodies of URLs, counting the requests made per URL.
+type countingClient struct {
+	bodies   map[string]string
+	requests map[string]int
+}
+
+func (client *countingClient) Get(_ context.Context, URL string) (*http.Response, error) {
+	client.requests[URL]++
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(client.bodies[URL])),
+	}, nil
+}
+
+func TestCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	client := &countingClient{
+		bodies: map[string]string{
+			"a":     "aaaa",
+			"b":     "bbbb",
+			"c":     "cccc",
+			"large": strings.Repeat("l", 11),
+		},
+		requests: map[string]int{},
+	}
+
+	cache := NewCache("", 0)
+
+	cache.MaxMemorySize = 10
+
+	cachingClient := cache.Client(client)
+
+	get := func(URL string) {
+		t.Helper()
+
+		res, err := cachingClient.Get(context.Background(), URL)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", URL, err)
+		}
+
+		body, _ := io.ReadAll(res.Body)
+
+		res.Body.Close()
+
+		if string(body) != client.bodies[URL] {
+			t.Errorf("Get(%q) = %q, want %q", URL, body, client.bodies[URL])
+		}
+	}
+
+	// a, used after b, outlives it once c doesn't fit in with both.
+	for _, URL := range []string{"a", "b", "a", "c", "a", "b", "c", "large", "large"} {
+		get(URL)
+	}
+
+	want := map[string]int{"a": 1, "b": 2, "c": 2, "large": 2}
+
+	for URL, requests := range want {
+		if client.requests[URL] != requests {
+			t.Errorf("%q requested %d times, want %d", URL, client.requests[URL], requests)
+		}
+	}
+
+	if cache.memorySize > cache.MaxMemorySize {
+		t.Errorf("cache holds %d bytes, want at most %d", cache.memorySize, cache.MaxMemorySize)
+	}
+}
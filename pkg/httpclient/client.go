@@ -1,10 +1,20 @@
 package httpclient
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hueristiq/hqgohttp"
 	"github.com/hueristiq/hqgohttp/methods"
@@ -12,41 +22,359 @@ import (
 	"github.com/hueristiq/xurlfind3r/internal/configuration"
 )
 
-var client *hqgohttp.Client
+// Options represents the configuration of the HTTP client.
+type Options struct {
+	// Timeout is the maximum time to wait for a request.
+	Timeout time.Duration
+	// RetryMax is the maximum number of retries on connection errors, 429 and 5xx responses.
+	RetryMax int
+	// RetryWaitMin is the minimum time to wait between retries.
+	RetryWaitMin time.Duration
+	// RetryWaitMax is the maximum time to wait between retries.
+	RetryWaitMax time.Duration
+	// Proxy is the URL of an HTTP(S) or SOCKS5 proxy to send requests through.
+	Proxy string
+	// UserAgents are rotated through, per request, as the User-Agent header.
+	// If not set, the xurlfind3r User-Agent is used.
+	UserAgents []string
+	// Headers are sent with every request, e.g. a `From` contact address or
+	// a mirror's credentials. Their User-Agent, if any, replaces UserAgents.
+	// Sources' own headers take precedence.
+	Headers http.Header
+	// MaxBodySize is the maximum size, in bytes, of response bodies, past
+	// which reading them fails with ErrBodyTooLarge. If not set, bodies
+	// aren't limited.
+	MaxBodySize int64
+	// InsecureSkipVerify disables the verification of servers' TLS
+	// certificates. It reduces security: only use it with trusted
+	// TLS-intercepting proxies or self-hosted mirrors.
+	InsecureSkipVerify bool
+	// CABundle is the path of a PEM file of CA certificates to trust on top
+	// of the system ones, e.g. for mirrors with a private certificate.
+	CABundle string
+	// AllowHTTPFallback retries, once, over plain HTTP, HTTPS GET requests
+	// that fail to connect after every retry, e.g. on TLS errors, but not
+	// those answered with an error, e.g. a 5xx. It reduces security:
+	// responses can then be read, and tampered with, in transit.
+	AllowHTTPFallback bool
+	// MaxConnsPerHost is the maximum number of connections, dialing, active
+	// and idle, to a host, past which requests wait for one to be freed. It
+	// bounds the sockets open: wayback snapshot workers, see the sources'
+	// Concurrency, beyond it wait for a connection instead of opening more.
+	// If not set, connections aren't limited.
+	MaxConnsPerHost int
+	// MaxIdleConnsPerHost, if set, keeps connections alive, for reuse, up to
+	// that many idle ones per host. If not set, connections are closed after
+	// every request.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long idle connections are kept alive. If not
+	// set, they're kept for 90 seconds.
+	IdleConnTimeout time.Duration
+	// Logger, if set, is warned of every fallback to plain HTTP.
+	Logger interface {
+		Warn(format string, args ...interface{})
+	}
+}
+
+// DefaultOptions is the configuration the HTTP client starts with.
+var DefaultOptions = &Options{
+	Timeout:         30 * time.Second,
+	RetryMax:        4,
+	RetryWaitMin:    1 * time.Second,
+	RetryWaitMax:    30 * time.Second,
+	MaxConnsPerHost: DefaultMaxConnsPerHost,
+}
+
+// DefaultMaxConnsPerHost is the maximum number of connections to a host of
+// DefaultOptions, a few times the default wayback snapshot workers.
+const DefaultMaxConnsPerHost = 32
+
+var (
+	// ErrTimeout is returned, wrapped, when a request exceeds the configured timeout.
+	ErrTimeout = errors.New("request timed out")
+	// ErrProxy is returned, wrapped, when a request fails to connect to the configured proxy.
+	ErrProxy = errors.New("proxy connection failed")
+)
+
+// Client is the interface for making GET requests, letting sources be given
+// an alternative to this package's HTTP client, e.g. for testing.
+type Client interface {
+	Get(ctx context.Context, URL string) (*http.Response, error)
+}
+
+type defaultClient struct{}
+
+func (defaultClient) Get(ctx context.Context, URL string) (*http.Response, error) {
+	return SimpleGet(ctx, URL)
+}
+
+// DefaultClient makes requests with this package's HTTP client.
+var DefaultClient Client = defaultClient{}
+
+// BrowserUserAgents is a list of common browser User-Agents, to rotate
+// through instead of the xurlfind3r User-Agent.
+var BrowserUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:120.0) Gecko/20100101 Firefox/120.0",
+	"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0",
+}
+
+// state is what requests are made with, replaced as a whole by Configure.
+// Requests load it once, so that they don't race with Configure.
+type state struct {
+	client *hqgohttp.Client
+
+	userAgents   []string
+	extraHeaders http.Header
+
+	allowHTTPFallback bool
+	fallbackLogger    interface {
+		Warn(format string, args ...interface{})
+	}
+
+	maxBodySize int64
+}
+
+var (
+	current atomic.Pointer[state]
+
+	userAgentCursor uint64
+
+	configureMutex sync.Mutex
+	// configured is a copy of the options Configure was called with, if any.
+	configured *Options
+)
+
+// ErrAlreadyConfigured is returned by Configure when the client, already
+// configured, is configured again with different options.
+var ErrAlreadyConfigured = errors.New("HTTP client already configured with different options")
 
 func init() {
-	options := hqgohttp.DefaultOptionsSpraying
+	configuredState, _ := newState(DefaultOptions)
+
+	current.Store(configuredState)
+}
+
+// Configure creates the HTTP client used by all requests with the given
+// options. The client is shared by the whole process: once configured, e.g.
+// by scraper.New, configuring it again with different options fails with
+// ErrAlreadyConfigured, for scrapers not to change each other's client while
+// in use.
+func Configure(options *Options) (err error) {
+	configureMutex.Lock()
+	defer configureMutex.Unlock()
+
+	if configured != nil {
+		if !reflect.DeepEqual(*configured, *options) {
+			err = ErrAlreadyConfigured
+		}
+
+		return
+	}
+
+	var configuredState *state
+
+	configuredState, err = newState(options)
+	if err != nil {
+		return
+	}
+
+	current.Store(configuredState)
+
+	copied := *options
+	if options.UserAgents != nil {
+		copied.UserAgents = append([]string{}, options.UserAgents...)
+	}
+	copied.Headers = options.Headers.Clone()
+
+	configured = &copied
+
+	return
+}
+
+func newState(options *Options) (configuredState *state, err error) {
+	clientOptions := *hqgohttp.DefaultOptionsSpraying
+
+	clientOptions.CheckRetry = checkRetry
+	clientOptions.Backoff = backoff()
+
+	if options.Timeout > 0 {
+		clientOptions.Timeout = options.Timeout
+	}
+
+	if options.RetryMax >= 0 {
+		clientOptions.RetryMax = options.RetryMax
+	}
+
+	if options.RetryWaitMin > 0 {
+		clientOptions.RetryWaitMin = options.RetryWaitMin
+	}
+
+	if options.RetryWaitMax > 0 {
+		clientOptions.RetryWaitMax = options.RetryWaitMax
+	}
+
+	var TLSConfig *tls.Config
+
+	TLSConfig, err = newTLSConfig(options)
+	if err != nil {
+		return
+	}
+
+	if options.Proxy != "" || TLSConfig != nil || options.MaxConnsPerHost > 0 || options.MaxIdleConnsPerHost > 0 {
+		HTTPClient := hqgohttp.DefaultHTTPClient()
+
+		transport, ok := HTTPClient.Transport.(*http.Transport)
+		if ok {
+			if options.Proxy != "" {
+				var proxyURL *url.URL
+
+				proxyURL, err = parseProxy(options.Proxy)
+				if err != nil {
+					return
+				}
+
+				transport.Proxy = http.ProxyURL(proxyURL)
+			}
+
+			if TLSConfig != nil {
+				transport.TLSClientConfig = TLSConfig
+			}
+
+			transport.MaxConnsPerHost = options.MaxConnsPerHost
+
+			if options.MaxIdleConnsPerHost > 0 {
+				transport.DisableKeepAlives = false
+				transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+			}
+
+			if options.IdleConnTimeout > 0 {
+				transport.IdleConnTimeout = options.IdleConnTimeout
+			}
+		}
+
+		clientOptions.HTTPClient = HTTPClient
+	}
+
+	var client *hqgohttp.Client
+
+	client, err = hqgohttp.New(&clientOptions)
+	if err != nil {
+		return
+	}
+
+	configuredState = &state{
+		client:            client,
+		userAgents:        append([]string(nil), options.UserAgents...),
+		extraHeaders:      options.Headers.Clone(),
+		allowHTTPFallback: options.AllowHTTPFallback,
+		fallbackLogger:    options.Logger,
+		maxBodySize:       options.MaxBodySize,
+	}
 
-	client, _ = hqgohttp.New(options)
+	return
 }
 
-func httpRequestWrapper(req *hqgohttp.Request) (res *http.Response, err error) {
-	res, err = client.Do(req)
+func (s *state) userAgent() string {
+	if len(s.userAgents) == 0 {
+		return fmt.Sprintf("%s v%s (https://github.com/hueristiq/%s)", configuration.NAME, configuration.VERSION, configuration.NAME)
+	}
+
+	cursor := atomic.AddUint64(&userAgentCursor, 1) - 1
+
+	return s.userAgents[cursor%uint64(len(s.userAgents))]
+}
+
+func httpRequestWrapper(s *state, req *hqgohttp.Request) (res *http.Response, err error) {
+	requestsCounter.Add(1)
+
+	res, err = s.client.Do(req)
+	if err != nil && s.allowHTTPFallback && req.URL.Scheme == "https" && req.Method == methods.Get && isConnectionError(err) && !isProxyError(err) && req.Context().Err() == nil {
+		DiscardResponse(res)
+
+		// a single attempt, the HTTPS request's retries being spent.
+		fallback := req.Clone(context.WithValue(req.Context(), hqgohttp.RetryMax, 0))
+
+		fallback.URL.Scheme = "http"
+		fallback.Host = ""
+
+		if s.fallbackLogger != nil {
+			s.fallbackLogger.Warn("httpclient: %s failed (%s), falling back to plain HTTP", redactURL(req.URL), redactError(err, req.URL))
+		}
+
+		requestsCounter.Add(1)
+
+		res, err = s.client.Do(fallback)
+	}
+
 	if err != nil {
+		switch {
+		case isProxyError(err):
+			err = fmt.Errorf("%w: %w", ErrProxy, err)
+		case isTimeout(err):
+			err = fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+
+		err = redactError(err, req.URL)
+
 		return
 	}
 
 	if res.StatusCode != status.OK {
-		requestURL, _ := url.QueryUnescape(req.URL.String())
+		requestURL, _ := url.QueryUnescape(redactURL(req.URL))
 
 		err = fmt.Errorf("unexpected status code %d received from %s", res.StatusCode, requestURL)
 
 		return
 	}
 
+	res.Body = &countingBody{ReadCloser: res.Body, counter: &wireBytesCounter}
+
+	if err = decodeBody(res); err != nil {
+		res = nil
+
+		return
+	}
+
+	res.Body = &countingBody{ReadCloser: res.Body, counter: &bytesCounter}
+
+	if err = limitBody(res, s.maxBodySize); err != nil {
+		res = nil
+
+		return
+	}
+
 	return
 }
 
 // HTTPRequest makes any HTTP request to a URL with extended parameters
-func HTTPRequest(method, requestURL, cookies string, headers map[string]string, body io.Reader) (*http.Response, error) {
-	req, err := hqgohttp.NewRequest(method, requestURL, body)
+func HTTPRequest(ctx context.Context, method, requestURL, cookies string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	req, err := hqgohttp.NewRequestWithContext(ctx, method, requestURL, body)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Accept", "*/*")
 	req.Header.Set("Accept-Language", "en")
-	req.Header.Set("User-Agent", fmt.Sprintf("%s v%s (https://github.com/hueristiq/%s)", configuration.NAME, configuration.VERSION, configuration.NAME))
+	// asked for explicitly, rather than by the transport, to count bytes
+	// transferred before decoding bodies; identity ones are left as they are.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	s := current.Load()
+
+	req.Header.Set("User-Agent", s.userAgent())
+
+	for key, values := range s.extraHeaders {
+		req.Header.Del(key)
+
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
 
 	if cookies != "" {
 		req.Header.Set("Cookie", cookies)
@@ -56,22 +384,99 @@ func HTTPRequest(method, requestURL, cookies string, headers map[string]string,
 		req.Header.Set(key, value)
 	}
 
-	return httpRequestWrapper(req)
+	if err = waitHost(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+
+	return httpRequestWrapper(s, req)
 }
 
 // Get makes a GET request to a URL with extended parameters
-func Get(URL, cookies string, headers map[string]string) (*http.Response, error) {
-	return HTTPRequest(methods.Get, URL, cookies, headers, nil)
+func Get(ctx context.Context, URL, cookies string, headers map[string]string) (*http.Response, error) {
+	return HTTPRequest(ctx, methods.Get, URL, cookies, headers, nil)
 }
 
 // SimpleGet makes a simple GET request to a URL
-func SimpleGet(URL string) (*http.Response, error) {
-	return HTTPRequest(methods.Get, URL, "", map[string]string{}, nil)
+func SimpleGet(ctx context.Context, URL string) (*http.Response, error) {
+	return HTTPRequest(ctx, methods.Get, URL, "", map[string]string{}, nil)
 }
 
 // Post makes a POST request to a URL with extended parameters
-func Post(URL, cookies string, headers map[string]string, body io.Reader) (*http.Response, error) {
-	return HTTPRequest(methods.Post, URL, cookies, headers, body)
+func Post(ctx context.Context, URL, cookies string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	return HTTPRequest(ctx, methods.Post, URL, cookies, headers, body)
+}
+
+func parseProxy(proxy string) (proxyURL *url.URL, err error) {
+	proxyURL, err = url.Parse(proxy)
+	if err != nil {
+		err = fmt.Errorf("invalid proxy URL: %w", err)
+
+		return
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		err = fmt.Errorf("invalid proxy URL %q: unsupported scheme %q", proxy, proxyURL.Scheme)
+
+		return
+	}
+
+	if proxyURL.Host == "" {
+		err = fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+
+		return
+	}
+
+	return
+}
+
+func isProxyError(err error) bool {
+	var opErr *net.OpError
+
+	if !errors.As(err, &opErr) {
+		return false
+	}
+
+	return opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks")
+}
+
+// isConnectionError reports whether err is a failure to connect, or to
+// complete a TLS handshake, rather than, e.g., a 5xx response.
+func isConnectionError(err error) bool {
+	var (
+		opErr                 *net.OpError
+		recordHeaderErr       tls.RecordHeaderError
+		verificationErr       *tls.CertificateVerificationError
+		unknownAuthorityErr   x509.UnknownAuthorityError
+		hostnameErr           x509.HostnameError
+		certificateInvalidErr x509.CertificateInvalidError
+	)
+
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	// net/http's, unwrapped, for plain HTTP servers' TLS record headers.
+	if strings.Contains(err.Error(), "server gave HTTP response to HTTPS client") {
+		return true
+	}
+
+	return errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 func DiscardResponse(response *http.Response) {
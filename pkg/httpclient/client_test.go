@@ -0,0 +1,214 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLogger counts the warnings it's given.
+type testLogger struct {
+	warnings atomic.Int64
+}
+
+func (logger *testLogger) Warn(_ string, _ ...interface{}) {
+	logger.warnings.Add(1)
+}
+
+// configure configures the client with options for the test's duration.
+func configure(t *testing.T, options *Options) {
+	t.Helper()
+
+	if err := Configure(options); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+
+	t.Cleanup(reset)
+}
+
+// reset undoes Configure, back to the default client.
+func reset() {
+	configureMutex.Lock()
+	defer configureMutex.Unlock()
+
+	defaultState, _ := newState(DefaultOptions)
+
+	current.Store(defaultState)
+
+	configured = nil
+}
+
+func TestHTTPFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		tls        bool
+		statusCode int
+		fallbacks  int64
+		requests   int64
+		wantErr    bool
+	}{
+		{
+			// the HTTPS request fails its TLS handshake with a plain HTTP
+			// server, which gets a single, plain, fallback request.
+			name:       "TLS error",
+			statusCode: http.StatusOK,
+			fallbacks:  1,
+			requests:   1,
+		},
+		{
+			name:       "TLS error, failed fallback",
+			statusCode: http.StatusServiceUnavailable,
+			fallbacks:  1,
+			requests:   1,
+			wantErr:    true,
+		},
+		{
+			// retried, then given up on, without a fallback.
+			name:       "5xx",
+			tls:        true,
+			statusCode: http.StatusServiceUnavailable,
+			requests:   3,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				requests.Add(1)
+
+				w.WriteHeader(tt.statusCode)
+			})
+
+			var server *httptest.Server
+
+			if tt.tls {
+				server = httptest.NewTLSServer(handler)
+			} else {
+				server = httptest.NewServer(handler)
+			}
+
+			defer server.Close()
+
+			logger := &testLogger{}
+
+			configure(t, &Options{
+				Timeout:            5 * time.Second,
+				RetryMax:           2,
+				RetryWaitMin:       time.Millisecond,
+				RetryWaitMax:       time.Millisecond,
+				InsecureSkipVerify: true,
+				AllowHTTPFallback:  true,
+				Logger:             logger,
+			})
+
+			URL := "https://" + strings.TrimPrefix(strings.TrimPrefix(server.URL, "https://"), "http://")
+
+			res, err := SimpleGet(context.Background(), URL)
+
+			DiscardResponse(res)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SimpleGet() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := logger.warnings.Load(); got != tt.fallbacks {
+				t.Errorf("fell back %d times, want %d", got, tt.fallbacks)
+			}
+
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("server got %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestConfigureTwice(t *testing.T) {
+	options := &Options{
+		Timeout:    5 * time.Second,
+		RetryMax:   1,
+		UserAgents: []string{"first"},
+		Headers:    http.Header{"X-Test": {"first"}},
+	}
+
+	configure(t, options)
+
+	same := *options
+	same.UserAgents = []string{"first"}
+	same.Headers = http.Header{"X-Test": {"first"}}
+
+	if err := Configure(&same); err != nil {
+		t.Errorf("Configure() with the same options error = %v", err)
+	}
+
+	// the options given aren't retained, changing them changes nothing.
+	options.Headers.Set("X-Test", "changed")
+
+	if err := Configure(&same); err != nil {
+		t.Errorf("Configure() with the same options error = %v", err)
+	}
+
+	different := same
+	different.UserAgents = []string{"second"}
+
+	if err := Configure(&different); !errors.Is(err, ErrAlreadyConfigured) {
+		t.Errorf("Configure() with different options error = %v, want %v", err, ErrAlreadyConfigured)
+	}
+
+	if got := current.Load().userAgents; len(got) != 1 || got[0] != "first" {
+		t.Errorf("user agents = %v, want [first]", got)
+	}
+}
+
+// TestConfigureConcurrently is meant for the race detector: requests in
+// flight while Configure is called.
+func TestConfigureConcurrently(t *testing.T) {
+	t.Cleanup(reset)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.UserAgent()))
+	}))
+
+	defer server.Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				res, err := SimpleGet(context.Background(), server.URL)
+				if err != nil {
+					t.Errorf("SimpleGet() error = %v", err)
+				}
+
+				DiscardResponse(res)
+			}
+		}()
+	}
+
+	options := &Options{
+		Timeout:     5 * time.Second,
+		UserAgents:  []string{"configured"},
+		MaxBodySize: 1 << 20,
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := Configure(options); err != nil {
+			t.Errorf("Configure() error = %v", err)
+		}
+	}
+
+	wg.Wait()
+}
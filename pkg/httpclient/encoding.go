@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hueristiq/hqgohttp/headers"
+)
+
+type decodedBody struct {
+	io.Reader
+
+	closers []io.Closer
+}
+
+func (body *decodedBody) Close() (err error) {
+	for _, closer := range body.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
+
+// decodeBody inflates gzip and deflate encoded bodies, as requests ask for. A
+// blank encoded body, as some servers answer with under load, is read as an
+// empty body rather than failing. On error, the body is closed.
+func decodeBody(res *http.Response) (err error) {
+	if res.Uncompressed {
+		return
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get(headers.ContentEncoding)))
+
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return
+	}
+
+	buffered := bufio.NewReader(res.Body)
+
+	var reader io.ReadCloser
+
+	if _, peekErr := buffered.Peek(1); peekErr == io.EOF {
+		reader = io.NopCloser(buffered)
+	} else if encoding == "deflate" {
+		// "deflate" is meant to be zlib wrapped, but raw deflate is common.
+		header, _ := buffered.Peek(2)
+
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			reader, err = zlib.NewReader(buffered)
+		} else {
+			reader = flate.NewReader(buffered)
+		}
+	} else {
+		reader, err = gzip.NewReader(buffered)
+	}
+
+	if err != nil {
+		res.Body.Close()
+
+		return
+	}
+
+	res.Body = &decodedBody{
+		Reader:  reader,
+		closers: []io.Closer{reader, res.Body},
+	}
+
+	res.Header.Del(headers.ContentEncoding)
+	res.Header.Del(headers.ContentLength)
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return
+}
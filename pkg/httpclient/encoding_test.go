@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// closedBody reports whether it's been closed.
+type closedBody struct {
+	io.Reader
+
+	closed bool
+}
+
+func (body *closedBody) Close() error {
+	body.closed = true
+
+	return nil
+}
+
+func compress(t *testing.T, encoding, content string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+
+	var writer io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buffer)
+	case "zlib":
+		writer = zlib.NewWriter(&buffer)
+	case "flate":
+		writer, _ = flate.NewWriter(&buffer, flate.DefaultCompression)
+	}
+
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Close()
+
+	return buffer.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	t.Parallel()
+
+	const content = `[["timestamp","original"]]`
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+		wantErr  bool
+	}{
+		{"gzip", "gzip", compress(t, "gzip", content), content, false},
+		{"x-gzip", "x-gzip", compress(t, "gzip", content), content, false},
+		{"zlib deflate", "deflate", compress(t, "zlib", content), content, false},
+		{"raw deflate", "deflate", compress(t, "flate", content), content, false},
+		{"identity", "", []byte(content), content, false},
+		{"empty gzip", "gzip", nil, "", false},
+		{"empty deflate", "deflate", nil, "", false},
+		{"malformed gzip", "gzip", []byte("not gzip"), "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := &closedBody{Reader: bytes.NewReader(tt.body)}
+
+			res := &http.Response{
+				Header: http.Header{},
+				Body:   body,
+			}
+
+			if tt.encoding != "" {
+				res.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			err := decodeBody(res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeBody() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				if !body.closed {
+					t.Error("decodeBody() failed without closing the body")
+				}
+
+				return
+			}
+
+			got, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("reading the decoded body error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("decoded body = %q, want %q", got, tt.want)
+			}
+
+			res.Body.Close()
+
+			if !body.closed {
+				t.Error("closing the decoded body didn't close the body")
+			}
+		})
+	}
+}
+
+func TestEmptyEncodedResponse(t *testing.T) {
+	configure(t, &Options{
+		Timeout:  5 * time.Second,
+		RetryMax: 0,
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer server.Close()
+
+	res, err := SimpleGet(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("SimpleGet() error = %v", err)
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading the body error = %v", err)
+	}
+
+	if len(body) != 0 {
+		t.Errorf("body = %q, want it empty", body)
+	}
+}
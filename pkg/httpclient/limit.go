@@ -0,0 +1,66 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is returned, wrapped, when a response body exceeds the
+// configured maximum size.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+type limitedBody struct {
+	io.ReadCloser
+
+	limit, remaining int64
+}
+
+func (body *limitedBody) Read(p []byte) (n int, err error) {
+	if body.remaining <= 0 {
+		// read a byte past the limit to tell exact-sized bodies apart.
+		var probe [1]byte
+
+		n, err = body.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, body.limit)
+		}
+
+		return
+	}
+
+	if int64(len(p)) > body.remaining {
+		p = p[:body.remaining]
+	}
+
+	n, err = body.ReadCloser.Read(p)
+
+	body.remaining -= int64(n)
+
+	return
+}
+
+// limitBody caps the response's body to maxBodySize bytes, if positive.
+// Responses announcing a larger body are rejected right away.
+func limitBody(res *http.Response, maxBodySize int64) (err error) {
+	if maxBodySize <= 0 {
+		return
+	}
+
+	if res.ContentLength > maxBodySize {
+		res.Body.Close()
+
+		err = fmt.Errorf("%w: %d bytes, more than %d bytes", ErrBodyTooLarge, res.ContentLength, maxBodySize)
+
+		return
+	}
+
+	res.Body = &limitedBody{
+		ReadCloser: res.Body,
+		limit:      maxBodySize,
+		remaining:  maxBodySize,
+	}
+
+	return
+}
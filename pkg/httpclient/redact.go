@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"net/url"
+	"strings"
+)
+
+// credentialParams are the query parameters, compared case insensitively,
+// whose values are credentials, e.g. Shodan's `key`, redacted from the
+// request URLs of errors and logs.
+var credentialParams = map[string]bool{
+	"key":          true,
+	"apikey":       true,
+	"api_key":      true,
+	"token":        true,
+	"access_token": true,
+	"secret":       true,
+	"password":     true,
+}
+
+// redacted replaces the credentials of redacted URLs.
+const redacted = "REDACTED"
+
+// redactQuery returns query with the values of its credential parameters
+// replaced, its parameters left in order.
+func redactQuery(query string) string {
+	if query == "" {
+		return query
+	}
+
+	params := strings.Split(query, "&")
+
+	for i, param := range params {
+		rawName, _, _ := strings.Cut(param, "=")
+
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+
+		if credentialParams[strings.ToLower(name)] {
+			params[i] = rawName + "=" + redacted
+		}
+	}
+
+	return strings.Join(params, "&")
+}
+
+// redactURL returns URL with the values of its credential query parameters,
+// and its password, if any, replaced.
+func redactURL(URL *url.URL) string {
+	redactedURL := *URL
+
+	redactedURL.RawQuery = redactQuery(URL.RawQuery)
+
+	return redactedURL.Redacted()
+}
+
+// redactedError is an error whose message has had credentials redacted.
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (err *redactedError) Error() string {
+	return err.message
+}
+
+func (err *redactedError) Unwrap() error {
+	return err.err
+}
+
+// redactError returns err with the credential query parameters of URL, as
+// e.g. transport and retry errors quote the request URL, redacted from its
+// message, should they be in it, escaped or not.
+func redactError(err error, URL *url.URL) error {
+	if err == nil {
+		return nil
+	}
+
+	query := URL.RawQuery
+
+	redactedQuery := redactQuery(query)
+	if redactedQuery == query {
+		return err
+	}
+
+	message := strings.ReplaceAll(err.Error(), query, redactedQuery)
+
+	if unescaped, unescapeErr := url.QueryUnescape(query); unescapeErr == nil {
+		message = strings.ReplaceAll(message, unescaped, redactedQuery)
+	}
+
+	return &redactedError{err: err, message: message}
+}
@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hueristiq/hqgohttp"
+	"github.com/hueristiq/hqgohttp/headers"
+	"github.com/hueristiq/hqgohttp/status"
+)
+
+// checkRetry retries on connection errors, 429 and 5xx responses, except 501.
+// Any other response is returned to the caller immediately.
+func checkRetry(ctx context.Context, res *http.Response, err error) (bool, error) {
+	if err != nil || ctx.Err() != nil {
+		return hqgohttp.CheckRecoverableErrors(ctx, res, err)
+	}
+
+	if res.StatusCode == status.TooManyRequests {
+		return true, nil
+	}
+
+	if res.StatusCode >= status.InternalServerError && res.StatusCode != status.NotImplemented {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// pausedHosts maps hosts that answered 429 with a Retry-After header to the
+// time until which no new request is sent to them.
+var pausedHosts sync.Map
+
+// pauseHost holds off new requests to host until the given time, shared by
+// every goroutine using the client.
+func pauseHost(host string, until time.Time) {
+	for {
+		current, loaded := pausedHosts.LoadOrStore(host, until)
+		if !loaded {
+			return
+		}
+
+		if !until.After(current.(time.Time)) {
+			return
+		}
+
+		if pausedHosts.CompareAndSwap(host, current, until) {
+			return
+		}
+	}
+}
+
+// waitHost blocks until host is no longer paused or ctx is done.
+func waitHost(ctx context.Context, host string) (err error) {
+	until, ok := pausedHosts.Load(host)
+	if !ok {
+		return
+	}
+
+	wait := time.Until(until.(time.Time))
+	if wait <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	return
+}
+
+// backoff waits for as long as the server asks to, through the Retry-After
+// header, otherwise falls back to exponential backoff with jitter. A 429's
+// Retry-After also pauses new requests to the host, so that concurrent
+// requests back off together instead of piling up more 429s.
+func backoff() hqgohttp.Backoff {
+	exponentialJitterBackoff := hqgohttp.ExponentialJitterBackoff()
+
+	return func(min, max time.Duration, attemptNum int, res *http.Response) time.Duration {
+		if res != nil {
+			if retryAfter, ok := ParseRetryAfter(res.Header.Get(headers.RetryAfter)); ok {
+				if retryAfter > max {
+					retryAfter = max
+				}
+
+				if res.StatusCode == status.TooManyRequests && res.Request != nil {
+					pauseHost(res.Request.URL.Host, time.Now().Add(retryAfter))
+				}
+
+				return retryAfter
+			}
+		}
+
+		return exponentialJitterBackoff(min, max, attemptNum, res)
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header value, given either in seconds
+// or as an HTTP-date, into the duration to wait.
+func ParseRetryAfter(value string) (retryAfter time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return
+	}
+
+	retryAfter = time.Until(date)
+
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+
+	ok = true
+
+	return
+}
@@ -0,0 +1,218 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoffRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		statusCode int
+		retryAfter string
+		max        time.Duration
+		want       time.Duration
+		paused     bool
+	}{
+		{"429", http.StatusTooManyRequests, "2", 30 * time.Second, 2 * time.Second, true},
+		{"429, capped", http.StatusTooManyRequests, "2", 500 * time.Millisecond, 500 * time.Millisecond, true},
+		{"503", http.StatusServiceUnavailable, "2", 30 * time.Second, 2 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			host := "backoff-" + tt.name + ".example.com"
+
+			t.Cleanup(func() {
+				pausedHosts.Delete(host)
+			})
+
+			res := &http.Response{
+				StatusCode: tt.statusCode,
+				Header:     http.Header{"Retry-After": {tt.retryAfter}},
+				Request:    &http.Request{URL: &url.URL{Scheme: "https", Host: host}},
+			}
+
+			before := time.Now()
+
+			if got := backoff()(time.Millisecond, tt.max, 1, res); got != tt.want {
+				t.Errorf("backoff() = %s, want %s", got, tt.want)
+			}
+
+			until, paused := pausedHosts.Load(host)
+			if paused != tt.paused {
+				t.Fatalf("host paused = %v, want %v", paused, tt.paused)
+			}
+
+			if paused {
+				if pause := until.(time.Time).Sub(before); pause < tt.want || pause > tt.want+time.Second {
+					t.Errorf("host paused for %s, want %s", pause, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// retryAfterServer answers its first request with a 429 and Retry-After: 2,
+// the next ones with a 200, recording when they're received.
+type retryAfterServer struct {
+	mutex    sync.Mutex
+	arrivals []time.Time
+
+	// limited is closed once the 429 is sent.
+	limited chan struct{}
+}
+
+func newRetryAfterServer(t *testing.T) (server *retryAfterServer, URL string) {
+	t.Helper()
+
+	server = &retryAfterServer{limited: make(chan struct{})}
+
+	httpServer := httptest.NewServer(server)
+
+	t.Cleanup(func() {
+		httpServer.Close()
+
+		pausedHosts.Delete(httpServer.Listener.Addr().String())
+	})
+
+	return server, httpServer.URL
+}
+
+func (server *retryAfterServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	server.mutex.Lock()
+	server.arrivals = append(server.arrivals, time.Now())
+	first := len(server.arrivals) == 1
+	server.mutex.Unlock()
+
+	if first {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+
+		close(server.limited)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (server *retryAfterServer) waits() (waits []time.Duration) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, arrival := range server.arrivals[1:] {
+		waits = append(waits, arrival.Sub(server.arrivals[0]))
+	}
+
+	return
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryWaitMax time.Duration
+		want         time.Duration
+	}{
+		{"honoured", 30 * time.Second, 2 * time.Second},
+		{"capped", 300 * time.Millisecond, 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configure(t, &Options{
+				Timeout:      5 * time.Second,
+				RetryMax:     1,
+				RetryWaitMin: time.Millisecond,
+				RetryWaitMax: tt.retryWaitMax,
+			})
+
+			server, URL := newRetryAfterServer(t)
+
+			res, err := SimpleGet(context.Background(), URL)
+			if err != nil {
+				t.Fatalf("SimpleGet() error = %v", err)
+			}
+
+			DiscardResponse(res)
+
+			waits := server.waits()
+			if len(waits) != 1 {
+				t.Fatalf("server got %d retries, want 1", len(waits))
+			}
+
+			if waits[0] < tt.want || waits[0] > tt.want+time.Second {
+				t.Errorf("retried after %s, want %s", waits[0], tt.want)
+			}
+		})
+	}
+}
+
+// TestRetryAfterPausesHost checks that a 429's Retry-After holds off new
+// requests to the host, not just the retry of the limited one.
+func TestRetryAfterPausesHost(t *testing.T) {
+	const pause = 500 * time.Millisecond
+
+	configure(t, &Options{
+		Timeout:      5 * time.Second,
+		RetryMax:     1,
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: pause,
+	})
+
+	server, URL := newRetryAfterServer(t)
+
+	var wg sync.WaitGroup
+
+	get := func() {
+		defer wg.Done()
+
+		res, err := SimpleGet(context.Background(), URL)
+		if err != nil {
+			t.Errorf("SimpleGet() error = %v", err)
+		}
+
+		DiscardResponse(res)
+	}
+
+	wg.Add(1)
+
+	go get()
+
+	<-server.limited
+
+	// for the client to read the 429, and pause the host.
+	time.Sleep(50 * time.Millisecond)
+
+	const concurrent = 4
+
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+
+		go get()
+	}
+
+	wg.Wait()
+
+	waits := server.waits()
+	if len(waits) != 1+concurrent {
+		t.Fatalf("server got %d requests after the 429, want %d", len(waits), 1+concurrent)
+	}
+
+	for _, wait := range waits {
+		if wait < pause {
+			t.Errorf("request sent %s after the 429, want it held off for %s", wait, pause)
+		}
+	}
+}
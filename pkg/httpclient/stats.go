@@ -0,0 +1,38 @@
+package httpclient
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+var (
+	requestsCounter  atomic.Int64
+	bytesCounter     atomic.Int64
+	wireBytesCounter atomic.Int64
+)
+
+// Counters returns the number of requests made, and response body bytes
+// read, after decompression, by the HTTP client since the process started.
+func Counters() (requests, bytes int64) {
+	return requestsCounter.Load(), bytesCounter.Load()
+}
+
+// WireBytes returns the number of response body bytes transferred, before
+// decompression, by the HTTP client since the process started.
+func WireBytes() int64 {
+	return wireBytesCounter.Load()
+}
+
+type countingBody struct {
+	io.ReadCloser
+
+	counter *atomic.Int64
+}
+
+func (body *countingBody) Read(p []byte) (n int, err error) {
+	n, err = body.ReadCloser.Read(p)
+
+	body.counter.Add(int64(n))
+
+	return
+}
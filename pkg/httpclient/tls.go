@@ -0,0 +1,56 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidCABundle is returned, wrapped, when the configured CA bundle
+// contains no PEM certificate.
+var ErrInvalidCABundle = errors.New("no certificate found in CA bundle")
+
+// newTLSConfig returns the TLS configuration for the given options, or nil
+// if they leave the default one unchanged.
+func newTLSConfig(options *Options) (config *tls.Config, err error) {
+	if !options.InsecureSkipVerify && options.CABundle == "" {
+		return
+	}
+
+	config = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// InsecureSkipVerify is opt-in, for TLS-intercepting proxies and
+		// self-hosted mirrors.
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CABundle != "" {
+		var pool *x509.CertPool
+
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		var PEM []byte
+
+		PEM, err = os.ReadFile(options.CABundle)
+		if err != nil {
+			err = fmt.Errorf("reading CA bundle: %w", err)
+
+			return
+		}
+
+		if !pool.AppendCertsFromPEM(PEM) {
+			err = fmt.Errorf("%w: %s", ErrInvalidCABundle, options.CABundle)
+
+			return
+		}
+
+		config.RootCAs = pool
+	}
+
+	return
+}
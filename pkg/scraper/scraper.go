@@ -1,73 +1,611 @@
 package scraper
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/bevigil"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/commoncrawl"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/github"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/intelx"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/otx"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/urlscan"
-	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources/wayback"
+
+	// register the built-in sources
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/archivetoday"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/bevigil"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/commoncrawl"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/github"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/intelx"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/otx"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/shodan"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/urlscan"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/virustotal"
+	_ "github.com/hueristiq/xurlfind3r/pkg/scraper/sources/wayback"
+)
+
+var (
+	// ErrUnknownSource is returned by New when a source to use is not supported.
+	ErrUnknownSource = errors.New("unknown source")
+	// ErrTooManyConsecutiveErrors is emitted, wrapped, when a scrape is wound
+	// down after MaxConsecutiveErrors errors in a row.
+	ErrTooManyConsecutiveErrors = errors.New("too many consecutive errors")
 )
 
 type Options struct {
-	IncludeSubdomains  bool
-	SourcesToUSe       []string
-	SourcesToExclude   []string
-	Keys               sources.Keys
-	ParseWaybackRobots bool
-	ParseWaybackSource bool
-	FilterPattern      string
-	Matchattern        string
+	IncludeSubdomains        bool
+	ExcludeHosts             []string
+	EmitOutOfScope           bool
+	SourcesToUSe             []string
+	SourcesToExclude         []string
+	Keys                     sources.Keys
+	ParseWaybackRobots       bool
+	ParseWaybackSource       bool
+	WaybackFrom              string
+	StateFile                string
+	WaybackTo                string
+	WaybackStatusCodes       []int
+	WaybackRateLimit         int
+	RateLimitJitter          time.Duration
+	WaybackSkipMetadata      bool
+	WaybackMatchType         string
+	WaybackCollapse          string
+	WaybackLatestOnly        bool
+	WaybackSnapshotsCollapse string
+	WaybackBaseURL           string
+	WaybackReplayModifier    string
+	SkipSourceExtensions     []string
+	ParseMIMETypes           []string
+	WaybackAvailability      bool
+	CommonCrawlIndexes       int
+	Concurrency              int
+	Timeout                  int
+	ScrapeTimeout            time.Duration
+	SourceConcurrency        int
+	MaxConsecutiveErrors     int
+	Retries                  *int
+	Proxy                    string
+	InsecureSkipVerify       bool
+	CABundle                 string
+	AllowHTTPFallback        bool
+	UserAgents               []string
+	Headers                  http.Header
+	MaxBodySize              int64
+	MaxConnsPerHost          int
+	MaxIdleConnsPerHost      int
+	Cache                    bool
+	CacheDir                 string
+	CacheTTL                 time.Duration
+	RefreshCache             bool
+	IncludeExtensions        []string
+	ExcludeExtensions        []string
+	FilterPattern            string
+	Matchattern              string
+	SortQueryParams          bool
+	AllowedSchemes           []string
+	UniquePaths              bool
+	KnownURLs                []string
+	SubdomainsOnly           bool
+	CountOnly                bool
+	DedupMode                string
+	DedupCapacity            int
+	DedupFalsePositiveRate   float64
+	CollapseParamValues      bool
+	MaxResults               int
+	MaxResultsPerSource      int
+	SortBy                   string
+	Logger                   sources.Logger
+	Transform                func(sources.Result) (sources.Result, bool)
+	OnProgress               func(sources.Stats)
+	ProgressInterval         time.Duration
 }
 
 type Finder struct {
 	Sources              map[string]sources.Source
 	SourcesConfiguration *sources.Configuration
+	IncludeExtensions    []string
+	ExcludeExtensions    []string
 	FilterRegex          *regexp.Regexp
 	MatchRegex           *regexp.Regexp
+	ScrapeTimeout        time.Duration
+	// SourceConcurrency is the maximum number of sources run at once. If not
+	// set, every source runs at once.
+	SourceConcurrency int
+	// MaxConsecutiveErrors winds a scrape down once its sources emit this many
+	// errors in a row, e.g. during an outage, any other result, be it a URL,
+	// even if then filtered out, an out of scope URL, with EmitOutOfScope, or
+	// a count, resetting the count. If not set, errors are unlimited.
+	MaxConsecutiveErrors int
 }
 
-func (finder *Finder) Scrape(domain string) (results chan sources.Result) {
+// Scrape runs the enabled sources concurrently against domain and merges their
+// results into a single channel, dropping URLs already emitted by any source.
+// The channel is closed once every source is done, ctx is cancelled or the
+// scrape timeout elapses, whatever was found until then being kept. domain
+// may be a URL, or a wildcard pattern, e.g. `*.gov`, each source translating
+// it, see sources.WildcardSource. It's normalized with
+// sources.NormalizeDomainPattern first, invalid ones yielding a single error
+// result.
+func (finder *Finder) Scrape(ctx context.Context, domain string) (results chan sources.Result) {
 	results = make(chan sources.Result)
 
+	// sorted results are emitted even once the scrape is cancelled, by
+	// MaxResults, unless the caller's context is done.
+	parent := ctx
+
+	var cancel context.CancelFunc
+
+	if finder.ScrapeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, finder.ScrapeTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
 	go func() {
 		defer close(results)
+		defer cancel()
+
+		normalized, wildcard, err := sources.NormalizeDomainPattern(domain)
+		if err != nil {
+			results <- sources.Result{
+				Type:   sources.Error,
+				Domain: domain,
+				Error:  err,
+			}
+
+			return
+		}
+
+		domain = normalized
+
+		config := finder.SourcesConfiguration
+
+		seenURLs := sources.NewSeen(config)
 
-		seenURLs := &sync.Map{}
+		for _, URL := range config.KnownURLs {
+			URL = sources.AddMissingScheme(URL)
+
+			if config.CollapseParamValues {
+				URL = sources.CollapseParamValues(URL)
+			}
+
+			seenURLs.Seen(finder.deduplicationKey(URL))
+		}
+		seenHosts := &sync.Map{}
+		seenOutOfScope := sources.NewSeen(config)
+
+		maxResults := int64(config.MaxResults)
+		maxResultsPerSource := config.MaxResultsPerSource
+
+		var emitted atomic.Int64
+
+		sortBy := config.SortBy
+		sorted := sortBy != "" && sortBy != sources.SortByNone
+
+		var collected []sources.Result
+
+		var collectedMu sync.Mutex
+
+		maxConsecutiveErrors := int64(finder.MaxConsecutiveErrors)
+
+		var consecutiveErrors atomic.Int64
+
+		stats := finder.trackProgress()
+		defer stats.stop()
 
 		wg := &sync.WaitGroup{}
 
+		var sem chan struct{}
+
+		if finder.SourceConcurrency > 0 {
+			sem = make(chan struct{}, finder.SourceConcurrency)
+		}
+
 		for name := range finder.Sources {
 			wg.Add(1)
 
 			go func(source sources.Source) {
 				defer wg.Done()
 
-				sResults := source.Run(finder.SourcesConfiguration, domain)
+				if sem != nil {
+					select {
+					case sem <- struct{}{}:
+						defer func() { <-sem }()
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				sourceCtx, sourceCancel := context.WithCancel(ctx)
+				defer sourceCancel()
+
+				sResults := run(sourceCtx, source, config, domain, wildcard)
+
+				emittedBySource := 0
 
 				for sResult := range sResults {
+					capped, sourceCapped, tripped := false, false, false
+
+					// once cancelled, drain the source's results while it winds down.
+					if sourceCtx.Err() != nil {
+						continue
+					}
+
+					if sResult.Type == sources.Error && maxConsecutiveErrors > 0 {
+						tripped = consecutiveErrors.Add(1) == maxConsecutiveErrors
+					}
+
+					// any other result tells the sources are making progress.
+					if sResult.Type != sources.Error {
+						consecutiveErrors.Store(0)
+					}
+
+					if sResult.Type == sources.OutOfScope {
+						sResult.Value = sources.AddMissingScheme(sResult.Value)
+
+						if !sources.IsAllowedURL(sResult.Value, config.AllowedSchemes) {
+							continue
+						}
+
+						if seenOutOfScope.Seen(sources.NormalizeURL(sResult.Value, config.SortQueryParams)) {
+							continue
+						}
+					}
+
 					if sResult.Type == sources.URL {
-						_, loaded := seenURLs.LoadOrStore(sResult.Value, struct{}{})
-						if loaded {
+						sResult.Value = sources.AddMissingScheme(sResult.Value)
+
+						if config.Transform != nil {
+							var keep bool
+
+							sResult, keep = config.Transform(sResult)
+							if !keep || sResult.Value == "" {
+								continue
+							}
+
+							sResult.Type = sources.URL
+						}
+
+						if !sources.IsAllowedURL(sResult.Value, config.AllowedSchemes) {
 							continue
 						}
 
-						if (finder.MatchRegex != nil && !finder.MatchRegex.MatchString(sResult.Value)) || (finder.FilterRegex != nil && finder.MatchRegex == nil && finder.FilterRegex.MatchString(sResult.Value)) {
+						if config.CollapseParamValues {
+							sResult.Value = sources.CollapseParamValues(sResult.Value)
+						}
+
+						if len(config.ExcludeHosts) > 0 && sources.MatchHost(sResult.Value, config.ExcludeHosts) {
 							continue
 						}
+
+						if len(finder.IncludeExtensions) > 0 && !sources.MatchExtension(sResult.Value, finder.IncludeExtensions) {
+							continue
+						}
+
+						if len(finder.ExcludeExtensions) > 0 && sources.MatchExtension(sResult.Value, finder.ExcludeExtensions) {
+							continue
+						}
+
+						// filter takes precedence over match
+						if finder.FilterRegex != nil && finder.FilterRegex.MatchString(sResult.Value) {
+							continue
+						}
+
+						if finder.MatchRegex != nil && !finder.MatchRegex.MatchString(sResult.Value) {
+							continue
+						}
+
+						// after the filters, lest a dropped URL claim its key, e.g. its
+						// path with UniquePaths.
+						if seenURLs.Seen(finder.deduplicationKey(sResult.Value)) {
+							continue
+						}
+
+						if config.SubdomainsOnly {
+							host := sources.ExtractHost(sResult.Value)
+							if host == "" {
+								continue
+							}
+
+							if _, loaded := seenHosts.LoadOrStore(host, struct{}{}); loaded {
+								continue
+							}
+
+							sResult = sources.Result{
+								Type:   sources.URL,
+								Source: sResult.Source,
+								Value:  host,
+							}
+						}
+
+						if maxResults > 0 {
+							count := emitted.Add(1)
+
+							if count > maxResults {
+								cancel()
+
+								continue
+							}
+
+							capped = count == maxResults
+						}
+
+						emittedBySource++
+
+						sourceCapped = maxResultsPerSource > 0 && emittedBySource >= maxResultsPerSource
+
+						if config.CountOnly {
+							stats.count(sResult)
+
+							if capped {
+								cancel()
+							}
+
+							if sourceCapped {
+								sourceCancel()
+							}
+
+							continue
+						}
+					}
+
+					sResult.Domain = domain
+
+					if sorted && sResult.Type == sources.URL {
+						collectedMu.Lock()
+						collected = append(collected, sResult)
+						collectedMu.Unlock()
+					} else {
+						results <- sResult
+					}
+
+					stats.count(sResult)
+
+					if capped {
+						cancel()
+					}
+
+					if sourceCapped {
+						sourceCancel()
+					}
+
+					if tripped {
+						results <- sources.Result{
+							Type:   sources.Error,
+							Domain: domain,
+							Source: source.Name(),
+							Error:  fmt.Errorf("%w: %d in a row, last: %w", ErrTooManyConsecutiveErrors, maxConsecutiveErrors, sResult.Error),
+						}
+
+						cancel()
 					}
+				}
 
-					results <- sResult
+				if config.CountOnly {
+					results <- sources.Result{
+						Type:   sources.Count,
+						Domain: domain,
+						Source: source.Name(),
+						Count:  int64(emittedBySource),
+					}
 				}
 			}(finder.Sources[name])
 		}
 
 		wg.Wait()
+
+		if !sorted {
+			return
+		}
+
+		sources.SortResults(collected, sortBy)
+
+		for _, result := range collected {
+			select {
+			case <-parent.Done():
+				return
+			case results <- result:
+			}
+		}
+	}()
+
+	return
+}
+
+// run runs source against domain or, for wildcard patterns, e.g. `*.gov`,
+// against every subdomain of domain: with its own translation of the pattern
+// if it's a sources.WildcardSource, with subdomains included otherwise. The
+// latter are skipped, with a single error result, for bare suffixes, which
+// they can't be queried for.
+func run(ctx context.Context, source sources.Source, config *sources.Configuration, domain string, wildcard bool) <-chan sources.Result {
+	if !wildcard {
+		return source.Run(ctx, config, domain)
+	}
+
+	if wildcardSource, ok := source.(sources.WildcardSource); ok {
+		return wildcardSource.RunWildcard(ctx, config, domain)
+	}
+
+	if strings.Contains(domain, ".") {
+		copied := *config
+
+		copied.IncludeSubdomains = true
+
+		return source.Run(ctx, &copied, domain)
+	}
+
+	results := make(chan sources.Result, 1)
+
+	results <- sources.Result{
+		Type:   sources.Error,
+		Source: source.Name(),
+		Error:  fmt.Errorf("%w: skipping *.%s", sources.ErrWildcardUnsupported, domain),
+	}
+
+	close(results)
+
+	return results
+}
+
+// deduplicationKey returns the key URLs are deduplicated on: their
+// normalized form, without query with UniquePaths.
+func (finder *Finder) deduplicationKey(URL string) (key string) {
+	key = sources.NormalizeURL(URL, finder.SourcesConfiguration.SortQueryParams)
+
+	if finder.SourcesConfiguration.UniquePaths {
+		key, _, _ = strings.Cut(key, "?")
+	}
+
+	return
+}
+
+// progress tracks the progress of a scrape, reporting it to OnProgress.
+type progress struct {
+	URLs   atomic.Int64
+	Errors atomic.Int64
+
+	start     time.Time
+	requests  int64
+	bytes     int64
+	wireBytes int64
+
+	onProgress func(sources.Stats)
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+func (finder *Finder) trackProgress() (tracker *progress) {
+	tracker = &progress{
+		start:      time.Now(),
+		onProgress: finder.SourcesConfiguration.OnProgress,
+	}
+
+	if tracker.onProgress == nil {
+		return
+	}
+
+	tracker.requests, tracker.bytes = httpclient.Counters()
+	tracker.wireBytes = httpclient.WireBytes()
+	tracker.done = make(chan struct{})
+	tracker.stopped = make(chan struct{})
+
+	interval := finder.SourcesConfiguration.ProgressInterval
+
+	if interval <= 0 {
+		interval = sources.DefaultProgressInterval
+	}
+
+	go func() {
+		defer close(tracker.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-tracker.done:
+				tracker.onProgress(tracker.stats())
+
+				return
+			case <-ticker.C:
+				tracker.onProgress(tracker.stats())
+			}
+		}
+	}()
+
+	return
+}
+
+func (tracker *progress) count(result sources.Result) {
+	switch result.Type {
+	case sources.URL:
+		tracker.URLs.Add(1)
+	case sources.Error:
+		tracker.Errors.Add(1)
+	}
+}
+
+func (tracker *progress) stats() (stats sources.Stats) {
+	requests, bytes := httpclient.Counters()
+
+	stats = sources.Stats{
+		URLs:      tracker.URLs.Load(),
+		Errors:    tracker.Errors.Load(),
+		Requests:  requests - tracker.requests,
+		Bytes:     bytes - tracker.bytes,
+		WireBytes: httpclient.WireBytes() - tracker.wireBytes,
+		Elapsed:   time.Since(tracker.start),
+	}
+
+	return
+}
+
+// stop reports the final progress, waiting for it to be.
+func (tracker *progress) stop() {
+	if tracker.onProgress == nil {
+		return
+	}
+
+	close(tracker.done)
+
+	<-tracker.stopped
+}
+
+// DefaultDomainsConcurrency is the number of domains ScrapeMany scrapes at
+// once when not told otherwise.
+const DefaultDomainsConcurrency = 5
+
+// ScrapeMany scrapes domains, concurrency of them at once, merging their
+// results into a single channel tagged with the domain each was found for.
+// URLs found for several domains are emitted once. The sources, and so their
+// rate limits, are shared across every domain.
+func (finder *Finder) ScrapeMany(ctx context.Context, domains []string, concurrency int) (results chan sources.Result) {
+	results = make(chan sources.Result)
+
+	if concurrency <= 0 {
+		concurrency = DefaultDomainsConcurrency
+	}
+
+	go func() {
+		defer close(results)
+
+		seenURLs := sources.NewSeen(finder.SourcesConfiguration)
+
+		wg := &sync.WaitGroup{}
+		sem := make(chan struct{}, concurrency)
+
+		for _, domain := range domains {
+			if ctx.Err() != nil {
+				break
+			}
+
+			wg.Add(1)
+
+			sem <- struct{}{}
+
+			go func(domain string) {
+				defer func() {
+					<-sem
+
+					wg.Done()
+				}()
+
+				for result := range finder.Scrape(ctx, domain) {
+					if result.Type == sources.URL {
+						if seenURLs.Seen(finder.deduplicationKey(result.Value)) {
+							continue
+						}
+					}
+
+					results <- result
+				}
+			}(domain)
+		}
+
+		wg.Wait()
 	}()
 
 	return
@@ -77,51 +615,141 @@ func New(options *Options) (finder *Finder, err error) {
 	finder = &Finder{
 		Sources: map[string]sources.Source{},
 		SourcesConfiguration: &sources.Configuration{
-			IncludeSubdomains:  options.IncludeSubdomains,
-			Keys:               options.Keys,
-			ParseWaybackRobots: options.ParseWaybackRobots,
-			ParseWaybackSource: options.ParseWaybackSource,
+			IncludeSubdomains:        options.IncludeSubdomains,
+			FilterPattern:            options.FilterPattern,
+			MatchPattern:             options.Matchattern,
+			ExcludeHosts:             options.ExcludeHosts,
+			EmitOutOfScope:           options.EmitOutOfScope,
+			Keys:                     options.Keys,
+			ParseWaybackRobots:       options.ParseWaybackRobots,
+			ParseWaybackSource:       options.ParseWaybackSource,
+			WaybackFrom:              options.WaybackFrom,
+			StateFile:                options.StateFile,
+			WaybackTo:                options.WaybackTo,
+			WaybackStatusCodes:       options.WaybackStatusCodes,
+			WaybackRateLimit:         options.WaybackRateLimit,
+			RateLimitJitter:          options.RateLimitJitter,
+			WaybackSkipMetadata:      options.WaybackSkipMetadata,
+			WaybackMatchType:         options.WaybackMatchType,
+			WaybackCollapse:          options.WaybackCollapse,
+			WaybackLatestOnly:        options.WaybackLatestOnly,
+			WaybackSnapshotsCollapse: options.WaybackSnapshotsCollapse,
+			WaybackBaseURL:           options.WaybackBaseURL,
+			WaybackReplayModifier:    options.WaybackReplayModifier,
+			SkipSourceExtensions:     options.SkipSourceExtensions,
+			ParseMIMETypes:           options.ParseMIMETypes,
+			WaybackAvailability:      options.WaybackAvailability,
+			CommonCrawlIndexes:       options.CommonCrawlIndexes,
+			SortQueryParams:          options.SortQueryParams,
+			AllowedSchemes:           options.AllowedSchemes,
+			UniquePaths:              options.UniquePaths,
+			KnownURLs:                options.KnownURLs,
+			SubdomainsOnly:           options.SubdomainsOnly,
+			CountOnly:                options.CountOnly,
+			DedupMode:                options.DedupMode,
+			DedupCapacity:            options.DedupCapacity,
+			DedupFalsePositiveRate:   options.DedupFalsePositiveRate,
+			CollapseParamValues:      options.CollapseParamValues,
+			MaxResults:               options.MaxResults,
+			MaxResultsPerSource:      options.MaxResultsPerSource,
+			SortBy:                   options.SortBy,
+			Logger:                   options.Logger,
+			Transform:                options.Transform,
+			OnProgress:               options.OnProgress,
+			ProgressInterval:         options.ProgressInterval,
+			Concurrency:              options.Concurrency,
+			Timeout:                  options.Timeout,
+			Cache:                    options.Cache,
+			CacheDir:                 options.CacheDir,
+			CacheTTL:                 options.CacheTTL,
+			RefreshCache:             options.RefreshCache,
 		},
+		ScrapeTimeout:        options.ScrapeTimeout,
+		SourceConcurrency:    options.SourceConcurrency,
+		MaxConsecutiveErrors: options.MaxConsecutiveErrors,
+		IncludeExtensions:    options.IncludeExtensions,
+		ExcludeExtensions:    options.ExcludeExtensions,
 	}
 
-	if options.FilterPattern != "" {
-		finder.FilterRegex, err = regexp.Compile(options.FilterPattern)
-		if err != nil {
+	if err = finder.SourcesConfiguration.Validate(); err != nil {
+		return
+	}
+
+	httpclientOptions := *httpclient.DefaultOptions
+
+	httpclientOptions.MaxBodySize = options.MaxBodySize
+
+	// if not set, the timeout is that set in the environment, if any.
+	if finder.SourcesConfiguration.Timeout == 0 {
+		envConfiguration := &sources.Configuration{}
+
+		if err = envConfiguration.LoadEnv(); err != nil {
 			return
 		}
+
+		finder.SourcesConfiguration.Timeout = envConfiguration.Timeout
 	}
 
-	if options.Matchattern != "" {
-		finder.MatchRegex, err = regexp.Compile(options.Matchattern)
-		if err != nil {
+	if finder.SourcesConfiguration.Timeout > 0 {
+		httpclientOptions.Timeout = time.Duration(finder.SourcesConfiguration.Timeout) * time.Second
+	}
+
+	if options.Retries != nil {
+		if *options.Retries < 0 {
+			err = fmt.Errorf("invalid retries %d, expected 0 or more", *options.Retries)
+
 			return
 		}
+
+		httpclientOptions.RetryMax = *options.Retries
+	}
+
+	if options.MaxConnsPerHost > 0 {
+		httpclientOptions.MaxConnsPerHost = options.MaxConnsPerHost
+	}
+
+	httpclientOptions.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+
+	httpclientOptions.Proxy = options.Proxy
+	httpclientOptions.InsecureSkipVerify = options.InsecureSkipVerify
+	httpclientOptions.CABundle = options.CABundle
+	httpclientOptions.AllowHTTPFallback = options.AllowHTTPFallback
+
+	if options.Logger != nil {
+		httpclientOptions.Logger = options.Logger
+	}
+	httpclientOptions.UserAgents = options.UserAgents
+	httpclientOptions.Headers = options.Headers
+
+	if err = httpclient.Configure(&httpclientOptions); err != nil {
+		return
+	}
+
+	// the patterns are valid, see sources.Configuration.Validate.
+	if options.FilterPattern != "" {
+		finder.FilterRegex = regexp.MustCompile(options.FilterPattern)
+	}
+
+	if options.Matchattern != "" {
+		finder.MatchRegex = regexp.MustCompile(options.Matchattern)
 	}
 
 	// Sources To Use
 	if len(options.SourcesToUSe) < 1 {
-		options.SourcesToUSe = sources.List
+		options.SourcesToUSe = sources.ListSources()
 	}
 
 	for index := range options.SourcesToUSe {
 		source := options.SourcesToUSe[index]
 
-		switch source {
-		case "bevigil":
-			finder.Sources[source] = &bevigil.Source{}
-		case "commoncrawl":
-			finder.Sources[source] = &commoncrawl.Source{}
-		case "github":
-			finder.Sources[source] = &github.Source{}
-		case "intelx":
-			finder.Sources[source] = &intelx.Source{}
-		case "otx":
-			finder.Sources[source] = &otx.Source{}
-		case "urlscan":
-			finder.Sources[source] = &urlscan.Source{}
-		case "wayback":
-			finder.Sources[source] = &wayback.Source{}
+		instance, ok := sources.NewSource(source)
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrUnknownSource, source)
+
+			return
 		}
+
+		finder.Sources[source] = instance
 	}
 
 	// Sources To Exclude
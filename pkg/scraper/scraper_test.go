@@ -0,0 +1,368 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// testSource emits a URL recording the domain, and subdomains setting, it's
+// run against.
+type testSource struct {
+	name string
+}
+
+func (source *testSource) Run(_ context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
+	results := make(chan sources.Result, 1)
+
+	URL := "https://" + domain + "/" + source.name
+
+	if config.IncludeSubdomains {
+		URL += "/subdomains"
+	}
+
+	results <- sources.Result{Type: sources.URL, Source: source.name, Value: URL}
+
+	close(results)
+
+	return results
+}
+
+func (source *testSource) Name() string {
+	return source.name
+}
+
+// testWildcardSource is a testSource translating wildcard patterns.
+type testWildcardSource struct {
+	testSource
+}
+
+func (source *testWildcardSource) RunWildcard(_ context.Context, _ *sources.Configuration, suffix string) <-chan sources.Result {
+	results := make(chan sources.Result, 1)
+
+	results <- sources.Result{Type: sources.URL, Source: source.name, Value: "https://" + suffix + "/" + source.name + "/wildcard"}
+
+	close(results)
+
+	return results
+}
+
+func TestScrapeWildcard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		domain   string
+		plain    string
+		wildcard string
+	}{
+		{
+			name:     "domain",
+			domain:   "example.com",
+			plain:    "https://example.com/plain",
+			wildcard: "https://example.com/wildcard",
+		},
+		{
+			name:     "wildcard domain",
+			domain:   "*.example.com",
+			plain:    "https://example.com/plain/subdomains",
+			wildcard: "https://example.com/wildcard/wildcard",
+		},
+		{
+			name:     "wildcard suffix",
+			domain:   "*.gov",
+			wildcard: "https://gov/wildcard/wildcard",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			finder := &Finder{
+				Sources: map[string]sources.Source{
+					"plain":    &testSource{name: "plain"},
+					"wildcard": &testWildcardSource{testSource{name: "wildcard"}},
+				},
+				SourcesConfiguration: &sources.Configuration{},
+			}
+
+			got := map[string]string{}
+
+			var errs []error
+
+			for result := range finder.Scrape(context.Background(), tt.domain) {
+				switch result.Type {
+				case sources.URL:
+					got[result.Source] = result.Value
+				case sources.Error:
+					if result.Source != "plain" {
+						t.Errorf("Scrape() error from %s = %v", result.Source, result.Error)
+					}
+
+					errs = append(errs, result.Error)
+				}
+			}
+
+			if got["plain"] != tt.plain {
+				t.Errorf("plain source emitted %q, want %q", got["plain"], tt.plain)
+			}
+
+			if got["wildcard"] != tt.wildcard {
+				t.Errorf("wildcard source emitted %q, want %q", got["wildcard"], tt.wildcard)
+			}
+
+			// sources that can't be queried for the pattern are skipped.
+			if tt.plain == "" && (len(errs) != 1 || !errors.Is(errs[0], sources.ErrWildcardUnsupported)) {
+				t.Errorf("Scrape() errors = %v, want a single %v", errs, sources.ErrWildcardUnsupported)
+			}
+		})
+	}
+}
+
+// testURLsSource emits the given URLs.
+type testURLsSource struct {
+	URLs []string
+}
+
+func (source *testURLsSource) Run(_ context.Context, _ *sources.Configuration, _ string) <-chan sources.Result {
+	results := make(chan sources.Result, len(source.URLs))
+
+	for _, URL := range source.URLs {
+		results <- sources.Result{Type: sources.URL, Source: source.Name(), Value: URL}
+	}
+
+	close(results)
+
+	return results
+}
+
+func (source *testURLsSource) Name() string {
+	return "urls"
+}
+
+// scrapeURLs scrapes example.com with a source emitting URLs, returning the
+// URLs output, sorted.
+func scrapeURLs(t *testing.T, config *sources.Configuration, URLs ...string) (got []string) {
+	t.Helper()
+
+	finder := &Finder{
+		Sources:              map[string]sources.Source{"urls": &testURLsSource{URLs: URLs}},
+		SourcesConfiguration: config,
+	}
+
+	for result := range finder.Scrape(context.Background(), "example.com") {
+		switch result.Type {
+		case sources.URL:
+			got = append(got, result.Value)
+		case sources.Error:
+			t.Errorf("Scrape() error = %v", result.Error)
+		}
+	}
+
+	sort.Strings(got)
+
+	return
+}
+
+func TestScrapeExcludeHosts(t *testing.T) {
+	t.Parallel()
+
+	config := &sources.Configuration{
+		IncludeSubdomains: true,
+		ExcludeHosts:      []string{"cdn.example.com", "*.tracking.example.com"},
+	}
+
+	got := scrapeURLs(t, config,
+		"https://example.com/",
+		"https://cdn.example.com/app.js",
+		"https://CDN.example.com:8443/app.css",
+		"https://a.tracking.example.com/pixel",
+		"https://b.a.tracking.example.com/pixel",
+		"https://tracking.example.com/",
+		"https://api.example.com/v1",
+		"https://example.com/cdn.example.com",
+	)
+
+	want := []string{
+		"https://api.example.com/v1",
+		"https://example.com/",
+		"https://example.com/cdn.example.com",
+		"https://tracking.example.com/",
+	}
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Scrape() = %v, want %v", got, want)
+	}
+}
+
+func TestScrapeSchemes(t *testing.T) {
+	t.Parallel()
+
+	URLs := []string{
+		"//cdn.example.com/x",
+		"example.com/path",
+		"example.com:443/secure",
+		"ftp://example.com/file",
+		"https://example.com/",
+		"mailto:info@example.com",
+		"javascript:void(0)",
+		"tel:+15555550100",
+		"https://x",
+	}
+
+	tests := []struct {
+		name    string
+		schemes []string
+		want    []string
+	}{
+		{
+			name: "default",
+			want: []string{
+				"http://cdn.example.com/x",
+				"http://example.com/path",
+				"https://example.com/",
+				"https://example.com:443/secure",
+				"https://x",
+			},
+		},
+		{
+			name:    "ftp allowed",
+			schemes: []string{"http", "https", "ftp"},
+			want: []string{
+				"ftp://example.com/file",
+				"http://cdn.example.com/x",
+				"http://example.com/path",
+				"https://example.com/",
+				"https://example.com:443/secure",
+				"https://x",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := scrapeURLs(t, &sources.Configuration{IncludeSubdomains: true, AllowedSchemes: tt.schemes}, URLs...)
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Scrape() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeUniquePathsFiltered(t *testing.T) {
+	t.Parallel()
+
+	finder := &Finder{
+		Sources: map[string]sources.Source{"urls": &testURLsSource{URLs: []string{
+			"https://example.com/search?q=a",
+			"https://example.com/search?q=b",
+			"https://example.com/search?q=b&page=2",
+		}}},
+		SourcesConfiguration: &sources.Configuration{UniquePaths: true},
+		MatchRegex:           regexp.MustCompile(`q=b`),
+	}
+
+	var got []string
+
+	for result := range finder.Scrape(context.Background(), "example.com") {
+		if result.Type == sources.URL {
+			got = append(got, result.Value)
+		}
+	}
+
+	want := []string{"https://example.com/search?q=b"}
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Scrape() = %v, want %v", got, want)
+	}
+}
+
+// testResultsSource emits the given results.
+type testResultsSource struct {
+	results []sources.Result
+}
+
+func (source *testResultsSource) Run(_ context.Context, _ *sources.Configuration, _ string) <-chan sources.Result {
+	results := make(chan sources.Result, len(source.results))
+
+	for _, result := range source.results {
+		result.Source = source.Name()
+
+		results <- result
+	}
+
+	close(results)
+
+	return results
+}
+
+func (source *testResultsSource) Name() string {
+	return "results"
+}
+
+// TestScrapeConsecutiveErrors checks that out of scope URLs and counts, as
+// URLs, reset the count of consecutive errors.
+func TestScrapeConsecutiveErrors(t *testing.T) {
+	t.Parallel()
+
+	failure := sources.Result{Type: sources.Error, Error: errors.New("failure")}
+
+	tests := []struct {
+		name     string
+		progress sources.Result
+		want     []string
+	}{
+		{"URL", sources.Result{Type: sources.URL, Value: "https://example.com/progress"}, []string{"https://example.com/last", "https://example.com/progress"}},
+		{"out of scope", sources.Result{Type: sources.OutOfScope, Value: "https://other.com/"}, []string{"https://example.com/last"}},
+		{"count", sources.Result{Type: sources.Count, Count: 1}, []string{"https://example.com/last"}},
+		{"none", failure, nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			finder := &Finder{
+				Sources: map[string]sources.Source{"results": &testResultsSource{results: []sources.Result{
+					failure,
+					failure,
+					tt.progress,
+					failure,
+					failure,
+					{Type: sources.URL, Value: "https://example.com/last"},
+				}}},
+				SourcesConfiguration: &sources.Configuration{EmitOutOfScope: true},
+				MaxConsecutiveErrors: 3,
+			}
+
+			var got []string
+
+			for result := range finder.Scrape(context.Background(), "example.com") {
+				if result.Type == sources.URL {
+					got = append(got, result.Value)
+				}
+			}
+
+			sort.Strings(got)
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Scrape() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package archivetoday
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/hueristiq/hqgohttp/status"
+	"github.com/hueristiq/hqgolimit"
+	"github.com/hueristiq/hqgourl"
+	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+type Source struct{}
+
+func init() {
+	sources.RegisterSource("archivetoday", func() sources.Source {
+		return &Source{}
+	})
+}
+
+// ErrCaptcha is returned when archive.today answers with a CAPTCHA, or some
+// other interstitial, instead of the listing.
+var ErrCaptcha = errors.New("archive.today returned a CAPTCHA page")
+
+// archive.today is quick to block scrapers.
+var limiter = hqgolimit.New(&hqgolimit.Options{
+	RequestsPerMinute: 10,
+})
+
+var (
+	// `<a id="next" style="..." href="https://archive.ph/offset=20/*.example.com">`
+	nextPageRegex = regexp.MustCompile(`<a[^>]+id="next"[^>]+href="([^"]+)"`)
+	captchaRegex  = regexp.MustCompile(`(?i)g-recaptcha|h-captcha|cf-challenge|<title>\s*Just a moment`)
+)
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
+	results := make(chan sources.Result)
+
+	go func() {
+		defer close(results)
+
+		var err error
+
+		var extractor *regexp.Regexp
+
+		extractor, err = hqgourl.Extractor.ModerateMatchHost(`(\w[a-zA-Z0-9][a-zA-Z0-9-\\.]*\.)?` + regexp.QuoteMeta(domain))
+		if err != nil {
+			result := sources.Result{
+				Type:   sources.Error,
+				Source: source.Name(),
+				Error:  err,
+			}
+
+			results <- result
+
+			return
+		}
+
+		query := domain + "*"
+
+		if config.IncludeSubdomains {
+			query = "*." + domain
+		}
+
+		getURLsReqURL := fmt.Sprintf("https://archive.ph/%s", query)
+
+		for getURLsReqURL != "" {
+			if ctx.Err() != nil {
+				return
+			}
+
+			limiter.Wait()
+
+			config.Jitter(ctx)
+
+			var getURLsRes *http.Response
+
+			getURLsRes, err = httpclient.SimpleGet(ctx, getURLsReqURL)
+			if err != nil {
+				if getURLsRes != nil && getURLsRes.StatusCode == status.TooManyRequests {
+					err = fmt.Errorf("%w: %w", ErrCaptcha, err)
+				}
+
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				httpclient.DiscardResponse(getURLsRes)
+
+				return
+			}
+
+			var body []byte
+
+			body, err = io.ReadAll(getURLsRes.Body)
+
+			getURLsRes.Body.Close()
+
+			if err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				return
+			}
+
+			content := string(body)
+
+			if captchaRegex.MatchString(content) {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  ErrCaptcha,
+				}
+
+				results <- result
+
+				return
+			}
+
+			for _, URL := range extractor.FindAllString(content, -1) {
+				URL = sources.FixURL(URL)
+
+				// skip the archive's own links
+				if strings.Contains(URL, "archive.ph/") || strings.Contains(URL, "archive.today/") {
+					continue
+				}
+
+				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, source.Name(), URL)
+
+					continue
+				}
+
+				result := sources.Result{
+					Type:   sources.URL,
+					Source: source.Name(),
+					Value:  URL,
+				}
+
+				results <- result
+			}
+
+			getURLsReqURL = ""
+
+			if match := nextPageRegex.FindStringSubmatch(content); match != nil {
+				getURLsReqURL = match[1]
+			}
+		}
+	}()
+
+	return results
+}
+
+// RunWildcard searches the archive for suffix's subdomains, with a `*.`
+// prefix wildcard, e.g. `*.gov`.
+func (source *Source) RunWildcard(ctx context.Context, config *sources.Configuration, suffix string) <-chan sources.Result {
+	copied := *config
+
+	copied.IncludeSubdomains = true
+
+	return source.Run(ctx, &copied, suffix)
+}
+
+func (source *Source) Name() string {
+	return "archivetoday"
+}
@@ -1,10 +1,14 @@
 package bevigil
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
+	"github.com/hueristiq/hqgolimit"
 	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
 )
@@ -16,7 +20,17 @@ type getURLsResponse struct {
 
 type Source struct{}
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
+var limiter = hqgolimit.New(&hqgolimit.Options{
+	RequestsPerMinute: 10,
+})
+
+func init() {
+	sources.RegisterSource("bevigil", func() sources.Source {
+		return &Source{}
+	})
+}
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
 	results := make(chan sources.Result)
 
 	go func() {
@@ -26,30 +40,24 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		var key string
 
-		key, err = sources.PickRandom(config.Keys.Bevigil)
-		if key == "" || err != nil {
-			result := sources.Result{
-				Type:   sources.Error,
-				Source: source.Name(),
-				Error:  err,
-			}
-
-			results <- result
-
+		key, err = sources.PickKey(source.Name(), config.Keys.Bevigil)
+		if err != nil {
 			return
 		}
 
-		getURLsReqHeaders := map[string]string{}
-
-		if len(config.Keys.Bevigil) > 0 {
-			getURLsReqHeaders["X-Access-Token"] = key
+		getURLsReqHeaders := map[string]string{
+			"X-Access-Token": key,
 		}
 
 		getURLsReqURL := fmt.Sprintf("https://osint.bevigil.com/api/%s/urls/", domain)
 
+		limiter.Wait()
+
+		config.Jitter(ctx)
+
 		var getURLsRes *http.Response
 
-		getURLsRes, err = httpclient.Get(getURLsReqURL, "", getURLsReqHeaders)
+		getURLsRes, err = httpclient.Get(ctx, getURLsReqURL, "", getURLsReqHeaders)
 		if err != nil {
 			result := sources.Result{
 				Type:   sources.Error,
@@ -66,7 +74,16 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		var getURLsResData getURLsResponse
 
-		if err = json.NewDecoder(getURLsRes.Body).Decode(&getURLsResData); err != nil {
+		err = json.NewDecoder(getURLsRes.Body).Decode(&getURLsResData)
+
+		// domains without data may come back with an empty body.
+		if errors.Is(err, io.EOF) {
+			getURLsRes.Body.Close()
+
+			return
+		}
+
+		if err != nil {
 			result := sources.Result{
 				Type:   sources.Error,
 				Source: source.Name(),
@@ -84,6 +101,8 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		for _, URL := range getURLsResData.URLs {
 			if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+				config.OutOfScope(results, source.Name(), URL)
+
 				continue
 			}
 
@@ -2,8 +2,10 @@ package commoncrawl
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -31,23 +33,34 @@ type getURLsResponse struct {
 
 type Source struct{}
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
+// indexesURL lists the index's collections, the CDX API of each.
+var indexesURL = "https://index.commoncrawl.org/collinfo.json"
+
+func init() {
+	sources.RegisterSource("commoncrawl", func() sources.Source {
+		return &Source{}
+	})
+}
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
 	results := make(chan sources.Result)
 
+	query := domain
+
 	if config.IncludeSubdomains {
-		domain = "*." + domain
+		query = "*." + domain
 	}
 
 	go func() {
 		defer close(results)
 
-		getIndexesReqURL := "https://index.commoncrawl.org/collinfo.json"
+		getIndexesReqURL := indexesURL
 
 		var err error
 
 		var getIndexesRes *http.Response
 
-		getIndexesRes, err = httpclient.SimpleGet(getIndexesReqURL)
+		getIndexesRes, err = httpclient.SimpleGet(ctx, getIndexesReqURL)
 		if err != nil {
 			result := sources.Result{
 				Type:   sources.Error,
@@ -80,38 +93,51 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		getIndexesRes.Body.Close()
 
-		year := time.Now().Year()
-		years := make([]string, 0)
-		maxYearsBack := 5
+		searchIndexes := make(map[string]string)
+
+		if config.CommonCrawlIndexes > 0 {
+			// indexes are listed from the most recent
+			for index := 0; index < len(getIndexesResData) && index < config.CommonCrawlIndexes; index++ {
+				CCIndex := getIndexesResData[index]
 
-		for i := 0; i < maxYearsBack; i++ {
-			years = append(years, strconv.Itoa(year-i))
-		}
+				searchIndexes[CCIndex.ID] = CCIndex.API
+			}
+		} else {
+			year := time.Now().Year()
+			years := make([]string, 0)
+			maxYearsBack := 5
 
-		searchIndexes := make(map[string]string)
+			for i := 0; i < maxYearsBack; i++ {
+				years = append(years, strconv.Itoa(year-i))
+			}
 
-		for _, year := range years {
-			for _, CCIndex := range getIndexesResData {
-				if strings.Contains(CCIndex.ID, year) {
-					if _, ok := searchIndexes[year]; !ok {
-						searchIndexes[year] = CCIndex.API
+			for _, year := range years {
+				for _, CCIndex := range getIndexesResData {
+					if strings.Contains(CCIndex.ID, year) {
+						if _, ok := searchIndexes[year]; !ok {
+							searchIndexes[year] = CCIndex.API
 
-						break
+							break
+						}
 					}
 				}
 			}
 		}
 
 		for _, CCIndexAPI := range searchIndexes {
+			if ctx.Err() != nil {
+				return
+			}
+
 			getURLsReqHeaders := map[string]string{
 				"Host": "index.commoncrawl.org",
 			}
 
-			getPaginationReqURL := fmt.Sprintf("%s?url=*.%s/*&output=json&fl=url&showNumPages=true", CCIndexAPI, domain)
+			getPaginationReqURL := fmt.Sprintf("%s?url=%s/*&output=json&fl=url&showNumPages=true", CCIndexAPI, query)
 
 			var getPaginationRes *http.Response
 
-			getPaginationRes, err = httpclient.SimpleGet(getPaginationReqURL)
+			getPaginationRes, err = httpclient.SimpleGet(ctx, getPaginationReqURL)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -149,11 +175,15 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 			}
 
 			for page := uint(0); page < getPaginationData.Pages; page++ {
-				getURLsReqURL := fmt.Sprintf("%s?url=*.%s/*&output=json&fl=url&page=%d", CCIndexAPI, domain, page)
+				if ctx.Err() != nil {
+					return
+				}
+
+				getURLsReqURL := fmt.Sprintf("%s?url=%s/*&output=json&fl=url&page=%d", CCIndexAPI, query, page)
 
 				var getURLsRes *http.Response
 
-				getURLsRes, err = httpclient.Get(getURLsReqURL, "", getURLsReqHeaders)
+				getURLsRes, err = httpclient.Get(ctx, getURLsReqURL, "", getURLsReqHeaders)
 				if err != nil {
 					result := sources.Result{
 						Type:   sources.Error,
@@ -168,75 +198,94 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 					continue
 				}
 
-				scanner := bufio.NewScanner(getURLsRes.Body)
-
-				for scanner.Scan() {
-					line := scanner.Text()
-					if line == "" {
-						continue
+				if err = source.parseURLs(getURLsRes.Body, config, domain, results); err != nil {
+					result := sources.Result{
+						Type:   sources.Error,
+						Source: source.Name(),
+						Error:  err,
 					}
 
-					var getURLsResData getURLsResponse
+					results <- result
+				}
 
-					if err = json.Unmarshal(scanner.Bytes(), &getURLsResData); err != nil {
-						result := sources.Result{
-							Type:   sources.Error,
-							Source: source.Name(),
-							Error:  err,
-						}
+				getURLsRes.Body.Close()
+			}
+		}
+	}()
 
-						results <- result
+	return results
+}
 
-						continue
-					}
+// parseURLs emits the in scope URLs of a page of the index's JSON lines.
+// Lines are scanned up to sources.MaxLineSize, as archived URLs can get very
+// long.
+func (source *Source) parseURLs(reader io.Reader, config *sources.Configuration, domain string, results chan<- sources.Result) (err error) {
+	scanner := bufio.NewScanner(reader)
 
-					if getURLsResData.Error != "" {
-						result := sources.Result{
-							Type:   sources.Error,
-							Source: source.Name(),
-							Error:  fmt.Errorf("%s", getURLsResData.Error),
-						}
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), sources.MaxLineSize)
 
-						results <- result
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
 
-						continue
-					}
+		var getURLsResData getURLsResponse
 
-					URL := getURLsResData.URL
+		if err := json.Unmarshal(scanner.Bytes(), &getURLsResData); err != nil {
+			result := sources.Result{
+				Type:   sources.Error,
+				Source: source.Name(),
+				Error:  err,
+			}
 
-					if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
-						continue
-					}
+			results <- result
 
-					result := sources.Result{
-						Type:   sources.URL,
-						Source: source.Name(),
-						Value:  URL,
-					}
+			continue
+		}
 
-					results <- result
-				}
+		if getURLsResData.Error != "" {
+			result := sources.Result{
+				Type:   sources.Error,
+				Source: source.Name(),
+				Error:  fmt.Errorf("%s", getURLsResData.Error),
+			}
 
-				if err = scanner.Err(); err != nil {
-					result := sources.Result{
-						Type:   sources.Error,
-						Source: source.Name(),
-						Error:  err,
-					}
+			results <- result
 
-					results <- result
+			continue
+		}
 
-					getURLsRes.Body.Close()
+		URL := getURLsResData.URL
 
-					continue
-				}
+		if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+			config.OutOfScope(results, source.Name(), URL)
 
-				getURLsRes.Body.Close()
-			}
+			continue
 		}
-	}()
 
-	return results
+		result := sources.Result{
+			Type:   sources.URL,
+			Source: source.Name(),
+			Value:  URL,
+		}
+
+		results <- result
+	}
+
+	err = scanner.Err()
+
+	return
+}
+
+// RunWildcard queries the index for suffix's subdomains, with a `*.` prefix
+// wildcard, e.g. `*.gov`.
+func (source *Source) RunWildcard(ctx context.Context, config *sources.Configuration, suffix string) <-chan sources.Result {
+	copied := *config
+
+	copied.IncludeSubdomains = true
+
+	return source.Run(ctx, &copied, suffix)
 }
 
 func (source *Source) Name() string {
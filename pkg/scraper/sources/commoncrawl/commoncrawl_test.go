@@ -0,0 +1,112 @@
+package commoncrawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// TestParseURLsLongLine checks that URLs longer than bufio's default 64KB
+// token limit are emitted, as are the lines after them.
+func TestParseURLsLongLine(t *testing.T) {
+	t.Parallel()
+
+	long := "https://example.com/?q=" + strings.Repeat("a", 100*1024)
+
+	body := fmt.Sprintf("{\"url\":%q}\n{\"url\":%q}\n{\"url\":%q}\n", "https://example.com/before", long, "https://example.com/after")
+
+	results := make(chan sources.Result)
+
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(results)
+
+		errs <- (&Source{}).parseURLs(strings.NewReader(body), &sources.Configuration{}, "example.com", results)
+	}()
+
+	var URLs []string
+
+	for result := range results {
+		switch result.Type {
+		case sources.URL:
+			URLs = append(URLs, result.Value)
+		case sources.Error:
+			t.Errorf("parseURLs() emitted error = %v", result.Error)
+		}
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("parseURLs() error = %v", err)
+	}
+
+	want := []string{"https://example.com/before", long, "https://example.com/after"}
+
+	if len(URLs) != len(want) {
+		t.Fatalf("parseURLs() emitted %d URLs, want %d", len(URLs), len(want))
+	}
+
+	for index := range want {
+		if URLs[index] != want[index] {
+			t.Errorf("parseURLs() URL %d = %.40q..., want %.40q...", index, URLs[index], want[index])
+		}
+	}
+}
+
+// TestRunSubdomains checks that, with subdomains, the index is queried for
+// the `*.` wildcard while scope is checked against the domain itself.
+func TestRunSubdomains(t *testing.T) {
+	var queries []string
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/collinfo.json":
+			fmt.Fprintf(w, `[{"id":"CC-MAIN-2024-10","cdx-API":%q}]`, server.URL+"/cdx")
+		case r.URL.Query().Get("showNumPages") == "true":
+			queries = append(queries, r.URL.Query().Get("url"))
+
+			fmt.Fprint(w, `{"pages":1}`)
+		default:
+			for _, URL := range []string{"https://example.com/", "https://sub.example.com/x", "https://evil-example.com/x", "https://example.com.evil.com/x"} {
+				fmt.Fprintf(w, "{\"url\":%q}\n", URL)
+			}
+		}
+	}))
+
+	defer server.Close()
+
+	defaultIndexesURL := indexesURL
+	indexesURL = server.URL + "/collinfo.json"
+
+	t.Cleanup(func() {
+		indexesURL = defaultIndexesURL
+	})
+
+	config := &sources.Configuration{IncludeSubdomains: true, CommonCrawlIndexes: 1}
+
+	var URLs []string
+
+	for result := range (&Source{}).Run(context.Background(), config, "example.com") {
+		switch result.Type {
+		case sources.URL:
+			URLs = append(URLs, result.Value)
+		case sources.Error:
+			t.Errorf("Run() error = %v", result.Error)
+		}
+	}
+
+	if want := "*.example.com/*"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("index queried for %q, want [%s]", queries, want)
+	}
+
+	if want := []string{"https://example.com/", "https://sub.example.com/x"}; strings.Join(URLs, " ") != strings.Join(want, " ") {
+		t.Errorf("Run() = %v, want %v", URLs, want)
+	}
+}
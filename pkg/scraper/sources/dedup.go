@@ -0,0 +1,149 @@
+package sources
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// Deduplication modes, for Configuration.DedupMode.
+const (
+	// DedupModeExact remembers every URL seen, its memory growing with the
+	// number of URLs.
+	DedupModeExact = "exact"
+	// DedupModeBloom remembers URLs seen in a bloom filter of fixed memory,
+	// sized for Configuration.DedupCapacity URLs. It may drop, at the
+	// configured false positive rate, URLs never seen before.
+	DedupModeBloom = "bloom"
+)
+
+// DedupModes are the supported deduplication modes.
+var DedupModes = []string{DedupModeExact, DedupModeBloom}
+
+const (
+	// DefaultDedupCapacity is the number of URLs bloom filters are sized for
+	// when the configuration doesn't specify it.
+	DefaultDedupCapacity = 10000000
+	// DefaultDedupFalsePositiveRate is the false positive rate bloom filters
+	// are sized for when the configuration doesn't specify it.
+	DefaultDedupFalsePositiveRate = 0.001
+)
+
+// Seen remembers the keys, e.g. normalized URLs, it's given. Implementations
+// are safe for concurrent use, a Seen being shared by every source of a scrape.
+type Seen interface {
+	// Seen reports whether key was given before, remembering it.
+	Seen(key string) bool
+}
+
+// NewSeen returns a Seen of the configured deduplication mode.
+func NewSeen(config *Configuration) Seen {
+	if config.DedupMode != DedupModeBloom {
+		return newExactSeen()
+	}
+
+	capacity := config.DedupCapacity
+
+	if capacity <= 0 {
+		capacity = DefaultDedupCapacity
+	}
+
+	rate := config.DedupFalsePositiveRate
+
+	if rate <= 0 || rate >= 1 {
+		rate = DefaultDedupFalsePositiveRate
+	}
+
+	return newBloomSeen(capacity, rate)
+}
+
+// exactSeenShards is the number of shards of an exact Seen, each behind its
+// own mutex, for sources not to contend on a single lock.
+const exactSeenShards = 64
+
+type exactSeenShard struct {
+	mutex sync.Mutex
+	keys  map[string]struct{}
+}
+
+type exactSeen struct {
+	shards [exactSeenShards]exactSeenShard
+}
+
+func newExactSeen() (seen *exactSeen) {
+	seen = &exactSeen{}
+
+	for index := range seen.shards {
+		seen.shards[index].keys = map[string]struct{}{}
+	}
+
+	return
+}
+
+func (seen *exactSeen) Seen(key string) (loaded bool) {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	shard := &seen.shards[hash.Sum32()%exactSeenShards]
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if _, loaded = shard.keys[key]; !loaded {
+		shard.keys[key] = struct{}{}
+	}
+
+	return
+}
+
+type bloomSeen struct {
+	mutex  sync.Mutex
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+// newBloomSeen sizes a bloom filter for capacity keys at the given false
+// positive rate: m = -n·ln(p)/ln(2)² bits and k = m/n·ln(2) hashes.
+func newBloomSeen(capacity int, rate float64) (seen *bloomSeen) {
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/float64(capacity)*math.Ln2)))
+
+	seen = &bloomSeen{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+
+	return
+}
+
+func (seen *bloomSeen) Seen(key string) (loaded bool) {
+	// double hashing, h1 + i·h2, from two independent hashes.
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+
+	sum1, sum2 := h1.Sum64(), h2.Sum64()|1
+
+	seen.mutex.Lock()
+	defer seen.mutex.Unlock()
+
+	loaded = true
+
+	for i := uint64(0); i < seen.hashes; i++ {
+		bit := (sum1 + i*sum2) % seen.size
+
+		word, mask := bit/64, uint64(1)<<(bit%64)
+
+		if seen.bits[word]&mask == 0 {
+			loaded = false
+
+			seen.bits[word] |= mask
+		}
+	}
+
+	return
+}
@@ -0,0 +1,103 @@
+package sources
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSeen(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range DedupModes {
+		mode := mode
+
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			seen := NewSeen(&Configuration{DedupMode: mode, DedupCapacity: 10000})
+
+			const keys = 1000
+
+			var wg sync.WaitGroup
+
+			var mutex sync.Mutex
+
+			// unseen counts, per key, the calls that reported it unseen.
+			unseen := map[string]int{}
+
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < keys; j++ {
+						key := fmt.Sprintf("https://example.com/%d", j)
+
+						if !seen.Seen(key) {
+							mutex.Lock()
+							unseen[key]++
+							mutex.Unlock()
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			// bloom filters may report unseen keys as seen, never the other
+			// way round.
+			for key, count := range unseen {
+				if count != 1 {
+					t.Errorf("Seen(%q) = false %d times, want once", key, count)
+				}
+			}
+
+			if mode == DedupModeExact && len(unseen) != keys {
+				t.Errorf("Seen() = false for %d keys, want %d", len(unseen), keys)
+			}
+		})
+	}
+}
+
+func BenchmarkSeen(b *testing.B) {
+	keys := make([]string, 1<<16)
+
+	for index := range keys {
+		keys[index] = NormalizeURL(fmt.Sprintf("https://example.com/path/%d?query=%d", index, index), true)
+	}
+
+	for _, mode := range DedupModes {
+		mode := mode
+
+		b.Run(mode, func(b *testing.B) {
+			seen := NewSeen(&Configuration{DedupMode: mode, DedupCapacity: len(keys)})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				seen.Seen(keys[i%len(keys)])
+			}
+		})
+
+		// as sources feed a scrape's Seen concurrently.
+		b.Run(mode+"/parallel", func(b *testing.B) {
+			seen := NewSeen(&Configuration{DedupMode: mode, DedupCapacity: len(keys)})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+
+				for pb.Next() {
+					seen.Seen(keys[i%len(keys)])
+
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// keysEnvironmentVariables are the environment variables API keys are read
+// from, by Keys.LoadEnv, per source. Several keys are comma(,) separated.
+var keysEnvironmentVariables = map[string]string{
+	"bevigil":    "BEVIGIL_API_KEY",
+	"github":     "GITHUB_TOKEN",
+	"shodan":     "SHODAN_API_KEY",
+	"urlscan":    "URLSCAN_API_KEY",
+	"virustotal": "VIRUSTOTAL_API_KEY",
+}
+
+// ConfigurationFromEnv returns a configuration with the API keys and options
+// set in the environment, see Configuration.LoadEnv.
+func ConfigurationFromEnv() (configuration *Configuration, err error) {
+	configuration = &Configuration{}
+
+	err = configuration.LoadEnv()
+
+	return
+}
+
+// LoadEnv merges the API keys set in the environment, see Keys.LoadEnv, into
+// the configuration, and sets its options left unset from the environment:
+// XURLFIND3R_WAYBACK_RATE_LIMIT, XURLFIND3R_CONCURRENCY and
+// XURLFIND3R_TIMEOUT, in seconds.
+func (configuration *Configuration) LoadEnv() (err error) {
+	configuration.Keys.LoadEnv()
+
+	options := []struct {
+		name  string
+		value *int
+	}{
+		{"XURLFIND3R_WAYBACK_RATE_LIMIT", &configuration.WaybackRateLimit},
+		{"XURLFIND3R_CONCURRENCY", &configuration.Concurrency},
+		{"XURLFIND3R_TIMEOUT", &configuration.Timeout},
+	}
+
+	for _, option := range options {
+		value := strings.TrimSpace(os.Getenv(option.name))
+
+		if value == "" || *option.value != 0 {
+			continue
+		}
+
+		if *option.value, err = strconv.Atoi(value); err != nil {
+			err = fmt.Errorf("invalid %s %q: %w", option.name, value, err)
+
+			return
+		}
+	}
+
+	return
+}
+
+// LoadEnv adds the API keys set in the environment to those already set:
+// BEVIGIL_API_KEY, GITHUB_TOKEN, SHODAN_API_KEY, URLSCAN_API_KEY,
+// VIRUSTOTAL_API_KEY and, as intelx keys are host:key pairs, INTELX_HOST with
+// INTELX_KEY.
+func (keys *Keys) LoadEnv() {
+	keys.Bevigil = appendEnvKeys(keys.Bevigil, os.Getenv(keysEnvironmentVariables["bevigil"]))
+	keys.GitHub = appendEnvKeys(keys.GitHub, os.Getenv(keysEnvironmentVariables["github"]))
+	keys.Shodan = appendEnvKeys(keys.Shodan, os.Getenv(keysEnvironmentVariables["shodan"]))
+	keys.URLScan = appendEnvKeys(keys.URLScan, os.Getenv(keysEnvironmentVariables["urlscan"]))
+	keys.VirusTotal = appendEnvKeys(keys.VirusTotal, os.Getenv(keysEnvironmentVariables["virustotal"]))
+
+	if host, key := strings.TrimSpace(os.Getenv("INTELX_HOST")), strings.TrimSpace(os.Getenv("INTELX_KEY")); host != "" && key != "" {
+		keys.Intelx = appendEnvKeys(keys.Intelx, host+":"+key)
+	}
+}
+
+// appendEnvKeys appends the comma(,) separated keys of value to keys, but
+// those already there.
+func appendEnvKeys(keys []string, value string) []string {
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+
+		if key == "" {
+			continue
+		}
+
+		exists := false
+
+		for _, existing := range keys {
+			if existing == key {
+				exists = true
+
+				break
+			}
+		}
+
+		if !exists {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
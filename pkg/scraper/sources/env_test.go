@@ -0,0 +1,61 @@
+package sources
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestLoadEnv checks that the API keys set in the environment are added to
+// those already set, and that options already set aren't overridden.
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghp_a, ghp_b")
+	t.Setenv("URLSCAN_API_KEY", "urlscan")
+	t.Setenv("INTELX_HOST", "2.intelx.io")
+	t.Setenv("INTELX_KEY", "intelx")
+	t.Setenv("XURLFIND3R_WAYBACK_RATE_LIMIT", "30")
+	t.Setenv("XURLFIND3R_CONCURRENCY", "8")
+	t.Setenv("XURLFIND3R_TIMEOUT", "45")
+
+	configuration := &Configuration{
+		Keys: Keys{
+			GitHub:     []string{"ghp_a", "ghp_file"},
+			VirusTotal: []string{"virustotal"},
+		},
+		Concurrency: 2,
+	}
+
+	if err := configuration.LoadEnv(); err != nil {
+		t.Fatalf("LoadEnv() error = %v", err)
+	}
+
+	wantKeys := Keys{
+		GitHub:     []string{"ghp_a", "ghp_file", "ghp_b"},
+		Intelx:     []string{"2.intelx.io:intelx"},
+		URLScan:    []string{"urlscan"},
+		VirusTotal: []string{"virustotal"},
+	}
+
+	if !reflect.DeepEqual(configuration.Keys, wantKeys) {
+		t.Errorf("LoadEnv() Keys = %+v, want %+v", configuration.Keys, wantKeys)
+	}
+
+	if configuration.WaybackRateLimit != 30 {
+		t.Errorf("LoadEnv() WaybackRateLimit = %d, want %d", configuration.WaybackRateLimit, 30)
+	}
+
+	if configuration.Concurrency != 2 {
+		t.Errorf("LoadEnv() Concurrency = %d, want %d", configuration.Concurrency, 2)
+	}
+
+	if configuration.Timeout != 45 {
+		t.Errorf("LoadEnv() Timeout = %d, want %d", configuration.Timeout, 45)
+	}
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	t.Setenv("XURLFIND3R_TIMEOUT", "45s")
+
+	if err := (&Configuration{}).LoadEnv(); err == nil {
+		t.Error("LoadEnv() error = nil, want an error")
+	}
+}
@@ -0,0 +1,175 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+
+	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+type gistResponse struct {
+	Files map[string]struct {
+		RawURL string `json:"raw_url"`
+	} `json:"files"`
+}
+
+var (
+	// gistSearchURL is the URL of GitHub's Gist search page, the API not
+	// searching Gists.
+	gistSearchURL = "https://gist.github.com/search"
+	// gistsURL is the URL of the API's Gists, each got by ID.
+	gistsURL = "https://api.github.com/gists/"
+
+	// gistRegex matches the links, and IDs, of the Gists listed in a search
+	// results page, e.g. `href="/user/aa5a315d61ae9438b18d"`.
+	gistRegex = regexp.MustCompile(`href="(?:https://gist\.github\.com)?/[\w.-]+/([0-9a-f]{20,32})"`)
+)
+
+// maxGistSearchPages caps the pages of Gist search results walked, each
+// listing up to 10 Gists.
+const maxGistSearchPages = 10
+
+// EnumerateGists searches Gists for domain, emitting the URLs found in the raw
+// content of every file of the Gists found, up to maxRawContentSize bytes of
+// each, as for code search results.
+func (source *Source) EnumerateGists(ctx context.Context, domain string, tokens *Tokens, results chan sources.Result, config *sources.Configuration) {
+	mdExtractor, err := newExtractor(domain)
+	if err != nil {
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: source.Name(),
+			Error:  err,
+		}
+
+		results <- result
+
+		return
+	}
+
+	seenGists := map[string]bool{}
+
+	for page := 1; page <= maxGistSearchPages; page++ {
+		if ctx.Err() != nil {
+			return
+		}
+
+		searchReqURL := fmt.Sprintf("%s?q=%s&p=%d", gistSearchURL, url.QueryEscape(`"`+domain+`"`), page)
+
+		IDs, err := source.searchGists(ctx, searchReqURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			result := sources.Result{
+				Type:   sources.Error,
+				Source: source.Name(),
+				Error:  err,
+			}
+
+			results <- result
+
+			return
+		}
+
+		found := 0
+
+		for _, ID := range IDs {
+			if seenGists[ID] {
+				continue
+			}
+
+			seenGists[ID] = true
+
+			found++
+
+			source.fetchGist(ctx, ID, mdExtractor, tokens, results, config, domain)
+		}
+
+		// past the last page, GitHub lists no Gists, or the last ones again.
+		if found == 0 {
+			return
+		}
+	}
+}
+
+// searchGists returns the IDs of the Gists listed in a search results page.
+func (source *Source) searchGists(ctx context.Context, searchReqURL string) (IDs []string, err error) {
+	searchRes, err := httpclient.SimpleGet(ctx, searchReqURL)
+	if err != nil {
+		httpclient.DiscardResponse(searchRes)
+
+		return
+	}
+
+	defer searchRes.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(searchRes.Body, maxRawContentSize))
+	if err != nil {
+		return
+	}
+
+	for _, match := range gistRegex.FindAllSubmatch(body, -1) {
+		IDs = append(IDs, string(match[1]))
+	}
+
+	return
+}
+
+// fetchGist emits the URLs found in the raw content of the files of the Gist
+// ID.
+func (source *Source) fetchGist(ctx context.Context, ID string, mdExtractor *regexp.Regexp, tokens *Tokens, results chan sources.Result, config *sources.Configuration, domain string) {
+	gistRes, err := source.get(ctx, gistsURL+ID, map[string]string{"Accept": "application/vnd.github+json"}, tokens)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: source.Name(),
+			Error:  err,
+		}
+
+		results <- result
+
+		httpclient.DiscardResponse(gistRes)
+
+		return
+	}
+
+	var gistResData gistResponse
+
+	err = json.NewDecoder(gistRes.Body).Decode(&gistResData)
+
+	gistRes.Body.Close()
+
+	if err != nil {
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: source.Name(),
+			Error:  err,
+		}
+
+		results <- result
+
+		return
+	}
+
+	for _, file := range gistResData.Files {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if file.RawURL == "" {
+			continue
+		}
+
+		source.fetchRawContent(ctx, file.RawURL, mdExtractor, config, domain, results)
+	}
+}
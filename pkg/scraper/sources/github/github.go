@@ -2,8 +2,10 @@ package github
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -32,7 +34,21 @@ type searchResponse struct {
 
 type Source struct{}
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
+func init() {
+	sources.RegisterSource("github", func() sources.Source {
+		return &Source{}
+	})
+}
+
+// XRatelimitReset is the header holding the time, in UTC epoch seconds, at
+// which the current rate limit window resets.
+const XRatelimitReset = "X-Ratelimit-Reset"
+
+// maxRawContentSize is the maximum number of bytes of a matched file's raw
+// content scanned for URLs, the rest of it being skipped.
+const maxRawContentSize = 1024 * 1024
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
 	results := make(chan sources.Result)
 
 	go func() {
@@ -46,37 +62,29 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		searchReqURL := fmt.Sprintf("https://api.github.com/search/code?per_page=100&q=%q&sort=created&order=asc", domain)
 
-		source.Enumerate(searchReqURL, domain, tokens, results, config)
+		source.Enumerate(ctx, searchReqURL, domain, tokens, results, config)
+
+		source.EnumerateGists(ctx, domain, tokens, results, config)
 	}()
 
 	return results
 }
 
-func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, results chan sources.Result, config *sources.Configuration) {
-	token := tokens.Get()
-
-	if token.RetryAfter > 0 {
-		if len(tokens.pool) == 1 {
-			time.Sleep(time.Duration(token.RetryAfter) * time.Second)
-		} else {
-			token = tokens.Get()
-		}
+func (source *Source) Enumerate(ctx context.Context, searchReqURL, domain string, tokens *Tokens, results chan sources.Result, config *sources.Configuration) {
+	if ctx.Err() != nil {
+		return
 	}
 
 	searchReqHeaders := map[string]string{
-		"Accept":        "application/vnd.github.v3.text-match+json",
-		"Authorization": "token " + token.Hash,
+		"Accept": "application/vnd.github.v3.text-match+json",
 	}
 
-	var err error
-
-	var searchRes *http.Response
-
-	searchRes, err = httpclient.Get(searchReqURL, "", searchReqHeaders)
-
-	isForbidden := searchRes != nil && searchRes.StatusCode == status.Forbidden
+	searchRes, err := source.get(ctx, searchReqURL, searchReqHeaders, tokens)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
 
-	if err != nil && !isForbidden {
 		result := sources.Result{
 			Type:   sources.Error,
 			Source: source.Name(),
@@ -90,15 +98,6 @@ func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, res
 		return
 	}
 
-	ratelimitRemaining := cast.ToInt64(searchRes.Header.Get(headers.XRatelimitRemaining))
-	if isForbidden && ratelimitRemaining == 0 {
-		retryAfterSeconds := cast.ToInt64(searchRes.Header.Get(headers.RetryAfter))
-
-		tokens.setCurrentTokenExceeded(retryAfterSeconds)
-
-		source.Enumerate(searchReqURL, domain, tokens, results, config)
-	}
-
 	var searchResData searchResponse
 
 	if err = json.NewDecoder(searchRes.Body).Decode(&searchResData); err != nil {
@@ -119,7 +118,7 @@ func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, res
 
 	var mdExtractor *regexp.Regexp
 
-	mdExtractor, err = hqgourl.Extractor.ModerateMatchHost(`(\w[a-zA-Z0-9][a-zA-Z0-9-\\.]*\.)?` + regexp.QuoteMeta(domain))
+	mdExtractor, err = newExtractor(domain)
 	if err != nil {
 		result := sources.Result{
 			Type:   sources.Error,
@@ -133,47 +132,19 @@ func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, res
 	}
 
 	for _, item := range searchResData.Items {
-		getRawContentReqURL := getRawContentURL(item.HTMLURL)
-
-		var getRawContentRes *http.Response
-
-		getRawContentRes, err = httpclient.SimpleGet(getRawContentReqURL)
-		if err != nil {
-			result := sources.Result{
-				Type:   sources.Error,
-				Source: source.Name(),
-				Error:  err,
-			}
-
-			results <- result
-
-			httpclient.DiscardResponse(getRawContentRes)
-
-			continue
-		}
-
-		if getRawContentRes.StatusCode != status.OK {
-			getRawContentRes.Body.Close()
-
-			continue
+		if ctx.Err() != nil {
+			return
 		}
 
-		scanner := bufio.NewScanner(getRawContentRes.Body)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue
-			}
+		source.fetchRawContent(ctx, getRawContentURL(item.HTMLURL), mdExtractor, config, domain, results)
 
-			URLs := mdExtractor.FindAllString(line, -1)
+		for _, textMatch := range item.TextMatches {
+			URLs := mdExtractor.FindAllString(textMatch.Fragment, -1)
 
 			for _, URL := range URLs {
 				URL = sources.FixURL(URL)
 
-				var parsedURL *hqgourl.URL
-
-				parsedURL, err = hqgourl.Parse(URL)
+				parsedURL, err := hqgourl.Parse(URL)
 				if err != nil {
 					result := sources.Result{
 						Type:   sources.Error,
@@ -189,6 +160,8 @@ func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, res
 				URL = parsedURL.String()
 
 				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, source.Name(), URL)
+
 					continue
 				}
 
@@ -201,64 +174,157 @@ func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, res
 				results <- result
 			}
 		}
+	}
 
-		if err = scanner.Err(); err != nil {
-			result := sources.Result{
-				Type:   sources.Error,
-				Source: source.Name(),
-				Error:  err,
+	linksHeader := linkheader.Parse(searchRes.Header.Get(headers.Link))
+
+	for _, link := range linksHeader {
+		if link.Rel == "next" {
+			nextURL, err := url.QueryUnescape(link.URL)
+			if err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				return
 			}
 
-			results <- result
+			source.Enumerate(ctx, nextURL, domain, tokens, results, config)
+		}
+	}
+}
 
-			getRawContentRes.Body.Close()
+// get gets reqURL, authenticated with the next token, waiting for every
+// token being rate limited to reset, and rotating past those found to be.
+func (source *Source) get(ctx context.Context, reqURL string, reqHeaders map[string]string, tokens *Tokens) (res *http.Response, err error) {
+	for {
+		token := tokens.Get()
 
-			continue
+		// every token is rate limited, wait for the current one to reset.
+		if token.RetryAfter > 0 {
+			wait := time.Duration(token.RetryAfter)*time.Second - time.Since(token.ExceededTime)
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
 		}
 
-		getRawContentRes.Body.Close()
+		authenticatedReqHeaders := map[string]string{
+			"Authorization": "token " + token.Hash,
+		}
 
-		for _, textMatch := range item.TextMatches {
-			URLs := mdExtractor.FindAllString(textMatch.Fragment, -1)
+		for key, value := range reqHeaders {
+			authenticatedReqHeaders[key] = value
+		}
 
-			for _, URL := range URLs {
-				URL = sources.FixURL(URL)
+		res, err = httpclient.Get(ctx, reqURL, "", authenticatedReqHeaders)
 
-				parsedURL, err := hqgourl.Parse(URL)
-				if err != nil {
-					result := sources.Result{
-						Type:   sources.Error,
-						Source: source.Name(),
-						Error:  err,
-					}
+		isRateLimited := res != nil &&
+			(res.StatusCode == status.Forbidden || res.StatusCode == status.TooManyRequests) &&
+			(res.Header.Get(headers.RetryAfter) != "" || res.Header.Get(headers.XRatelimitRemaining) == "0")
 
-					results <- result
+		if !isRateLimited {
+			return
+		}
 
-					continue
-				}
+		tokens.setCurrentTokenExceeded(token, getRetryAfter(res.Header))
 
-				URL = parsedURL.String()
+		httpclient.DiscardResponse(res)
+	}
+}
 
-				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
-					continue
-				}
+// newExtractor returns the extractor of the URLs of domain, or its
+// subdomains, found in files.
+func newExtractor(domain string) (*regexp.Regexp, error) {
+	return hqgourl.Extractor.ModerateMatchHost(`(\w[a-zA-Z0-9][a-zA-Z0-9-\\.]*\.)?` + regexp.QuoteMeta(domain))
+}
 
-				result := sources.Result{
-					Type:   sources.URL,
-					Source: source.Name(),
-					Value:  URL,
-				}
+// fetchRawContent emits the URLs found in the raw content at rawURL, up to
+// maxRawContentSize bytes of it.
+func (source *Source) fetchRawContent(ctx context.Context, rawURL string, mdExtractor *regexp.Regexp, config *sources.Configuration, domain string, results chan sources.Result) {
+	rawRes, err := httpclient.SimpleGet(ctx, rawURL)
+	if err != nil {
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: source.Name(),
+			Error:  err,
+		}
 
-				results <- result
-			}
+		results <- result
+
+		httpclient.DiscardResponse(rawRes)
+
+		return
+	}
+
+	defer rawRes.Body.Close()
+
+	if rawRes.StatusCode != status.OK {
+		return
+	}
+
+	if err = source.parseRawContent(io.LimitReader(rawRes.Body, maxRawContentSize), mdExtractor, config, domain, results); err != nil {
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: source.Name(),
+			Error:  err,
 		}
+
+		results <- result
 	}
+}
 
-	linksHeader := linkheader.Parse(searchRes.Header.Get(headers.Link))
+// getRetryAfter returns the number of seconds to wait before the token can be
+// used again, from either the Retry-After or the X-RateLimit-Reset header.
+func getRetryAfter(header http.Header) (seconds int64) {
+	if retryAfter, ok := httpclient.ParseRetryAfter(header.Get(headers.RetryAfter)); ok {
+		seconds = int64(retryAfter / time.Second)
+	} else if reset := cast.ToInt64(header.Get(XRatelimitReset)); reset > 0 {
+		seconds = reset - time.Now().Unix()
+	}
 
-	for _, link := range linksHeader {
-		if link.Rel == "next" {
-			nextURL, err := url.QueryUnescape(link.URL)
+	// wait at least a second, so that the token is marked as exceeded.
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return
+}
+
+func getRawContentURL(htmlURL string) string {
+	domain := strings.ReplaceAll(htmlURL, "https://github.com/", "https://raw.githubusercontent.com/")
+
+	return strings.ReplaceAll(domain, "/blob/", "/")
+}
+
+// parseRawContent emits the in scope URLs, matched by mdExtractor, found in a
+// matched file's raw content. Lines are scanned up to sources.MaxLineSize, as
+// minified files can get very long.
+func (source *Source) parseRawContent(reader io.Reader, mdExtractor *regexp.Regexp, config *sources.Configuration, domain string, results chan<- sources.Result) (err error) {
+	scanner := bufio.NewScanner(reader)
+
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), sources.MaxLineSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		URLs := mdExtractor.FindAllString(line, -1)
+
+		for _, URL := range URLs {
+			URL = sources.FixURL(URL)
+
+			var parsedURL *hqgourl.URL
+
+			parsedURL, err = hqgourl.Parse(URL)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -268,18 +334,30 @@ func (source *Source) Enumerate(searchReqURL, domain string, tokens *Tokens, res
 
 				results <- result
 
-				return
+				continue
+			}
+
+			URL = parsedURL.String()
+
+			if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+				config.OutOfScope(results, source.Name(), URL)
+
+				continue
+			}
+
+			result := sources.Result{
+				Type:   sources.URL,
+				Source: source.Name(),
+				Value:  URL,
 			}
 
-			source.Enumerate(nextURL, domain, tokens, results, config)
+			results <- result
 		}
 	}
-}
 
-func getRawContentURL(htmlURL string) string {
-	domain := strings.ReplaceAll(htmlURL, "https://github.com/", "https://raw.githubusercontent.com/")
+	err = scanner.Err()
 
-	return strings.ReplaceAll(domain, "/blob/", "/")
+	return
 }
 
 func (source *Source) Name() string {
@@ -0,0 +1,150 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hueristiq/hqgourl"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// TestParseRawContentLongLine checks that URLs on lines longer than bufio's
+// default 64KB token limit, e.g. of minified files, are emitted, as are those
+// of the lines after them.
+func TestParseRawContentLongLine(t *testing.T) {
+	t.Parallel()
+
+	mdExtractor, err := hqgourl.Extractor.ModerateMatchHost(`(\w[a-zA-Z0-9][a-zA-Z0-9-\\.]*\.)?` + regexp.QuoteMeta("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := "https://example.com/long?q=" + strings.Repeat("a", 100*1024)
+
+	content := "var before = \"https://example.com/before\";\n" +
+		"var long = \"" + long + "\";\n" +
+		"var after = \"https://example.com/after\";\n"
+
+	results := make(chan sources.Result)
+
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(results)
+
+		errs <- (&Source{}).parseRawContent(strings.NewReader(content), mdExtractor, &sources.Configuration{}, "example.com", results)
+	}()
+
+	got := map[string]bool{}
+
+	for result := range results {
+		switch result.Type {
+		case sources.URL:
+			got[result.Value] = true
+		case sources.Error:
+			t.Errorf("parseRawContent() emitted error = %v", result.Error)
+		}
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("parseRawContent() error = %v", err)
+	}
+
+	for _, want := range []string{"https://example.com/before", long, "https://example.com/after"} {
+		if !got[want] {
+			t.Errorf("parseRawContent() didn't emit %.40q...", want)
+		}
+	}
+}
+
+// TestEnumerateGists checks that the URLs in the files of the Gists listed by
+// every search results page are emitted, until a page lists no new Gists.
+func TestEnumerateGists(t *testing.T) {
+	var server *httptest.Server
+
+	pages := 0
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			pages++
+
+			if r.URL.Query().Get("q") != `"example.com"` {
+				t.Errorf("searched %q, want %q", r.URL.Query().Get("q"), `"example.com"`)
+			}
+
+			switch r.URL.Query().Get("p") {
+			case "1":
+				fmt.Fprint(w, `<a href="/alice/aa5a315d61ae9438b18d">`+
+					`<a href="https://gist.github.com/alice/aa5a315d61ae9438b18d">`+
+					`<a href="/bob/bb5a315d61ae9438b18d">`)
+			default:
+				fmt.Fprint(w, `<a href="/bob/bb5a315d61ae9438b18d">`)
+			}
+		case "/gists/aa5a315d61ae9438b18d":
+			if r.Header.Get("Authorization") != "token test" {
+				t.Errorf("Authorization = %q, want %q", r.Header.Get("Authorization"), "token test")
+			}
+
+			fmt.Fprintf(w, `{"files":{"a.txt":{"raw_url":"%[1]s/raw/a.txt"},"b.js":{"raw_url":"%[1]s/raw/b.js"}}}`, server.URL)
+		case "/gists/bb5a315d61ae9438b18d":
+			fmt.Fprintf(w, `{"files":{"c.md":{"raw_url":"%s/raw/c.md"}}}`, server.URL)
+		case "/raw/a.txt":
+			fmt.Fprint(w, "see https://example.com/a\n")
+		case "/raw/b.js":
+			fmt.Fprint(w, `fetch("https://api.example.com/b");`)
+		case "/raw/c.md":
+			fmt.Fprint(w, "[c](https://example.com/c) [other](https://other.com/c)\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer server.Close()
+
+	defaultGistSearchURL, defaultGistsURL := gistSearchURL, gistsURL
+	gistSearchURL, gistsURL = server.URL+"/search", server.URL+"/gists/"
+
+	t.Cleanup(func() {
+		gistSearchURL, gistsURL = defaultGistSearchURL, defaultGistsURL
+	})
+
+	results := make(chan sources.Result)
+
+	go func() {
+		defer close(results)
+
+		config := &sources.Configuration{IncludeSubdomains: true}
+
+		(&Source{}).EnumerateGists(context.Background(), "example.com", NewTokenManager([]string{"test"}), results, config)
+	}()
+
+	var URLs []string
+
+	for result := range results {
+		switch result.Type {
+		case sources.URL:
+			URLs = append(URLs, result.Value)
+		case sources.Error:
+			t.Errorf("EnumerateGists() error = %v", result.Error)
+		}
+	}
+
+	sort.Strings(URLs)
+
+	want := []string{"https://api.example.com/b", "https://example.com/a", "https://example.com/c"}
+
+	if strings.Join(URLs, " ") != strings.Join(want, " ") {
+		t.Errorf("EnumerateGists() = %v, want %v", URLs, want)
+	}
+
+	if pages != 2 {
+		t.Errorf("EnumerateGists() searched %d pages, want 2", pages)
+	}
+}
@@ -27,26 +27,36 @@ func NewTokenManager(keys []string) *Tokens {
 	}
 }
 
-func (r *Tokens) setCurrentTokenExceeded(retryAfter int64) {
-	if r.current >= len(r.pool) {
-		r.current %= len(r.pool)
-	}
-
-	if r.pool[r.current].RetryAfter == 0 {
-		r.pool[r.current].ExceededTime = time.Now()
-		r.pool[r.current].RetryAfter = retryAfter
+func (r *Tokens) setCurrentTokenExceeded(token *Token, retryAfter int64) {
+	if token.RetryAfter == 0 {
+		token.ExceededTime = time.Now()
+		token.RetryAfter = retryAfter
 	}
 }
 
+// Get returns the next token, in round-robin order, skipping rate limited
+// tokens. If every token is rate limited, the one resetting first is returned.
 func (r *Tokens) Get() *Token {
 	resetExceededTokens(r)
 
-	if r.current >= len(r.pool) {
-		r.current %= len(r.pool)
-	}
+	var result *Token
+
+	for i := 0; i < len(r.pool); i++ {
+		if r.current >= len(r.pool) {
+			r.current %= len(r.pool)
+		}
 
-	result := &r.pool[r.current]
-	r.current++
+		token := &r.pool[r.current]
+		r.current++
+
+		if token.RetryAfter == 0 {
+			return token
+		}
+
+		if result == nil || token.ExceededTime.Add(time.Duration(token.RetryAfter)*time.Second).Before(result.ExceededTime.Add(time.Duration(result.RetryAfter)*time.Second)) {
+			result = token
+		}
+	}
 
 	return result
 }
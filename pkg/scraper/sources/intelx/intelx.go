@@ -2,6 +2,7 @@ package intelx
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -34,7 +35,22 @@ type getResultsResponse struct {
 
 type Source struct{}
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
+const (
+	// maxPollAttempts bounds the number of times the search results are
+	// polled for.
+	maxPollAttempts = 30
+	// pollInterval is the time waited before polling the search results
+	// again while they aren't ready.
+	pollInterval = 2 * time.Second
+)
+
+func init() {
+	sources.RegisterSource("intelx", func() sources.Source {
+		return &Source{}
+	})
+}
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
 	results := make(chan sources.Result)
 
 	go func() {
@@ -44,12 +60,18 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		var key string
 
-		key, err = sources.PickRandom(config.Keys.Intelx)
-		if key == "" || err != nil {
+		key, err = sources.PickKey(source.Name(), config.Keys.Intelx)
+		if err != nil {
+			return
+		}
+
+		parts := strings.Split(key, ":")
+
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 			result := sources.Result{
 				Type:   sources.Error,
 				Source: source.Name(),
-				Error:  err,
+				Error:  fmt.Errorf("invalid key format, expected host:key"),
 			}
 
 			results <- result
@@ -57,18 +79,9 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 			return
 		}
 
-		parts := strings.Split(key, ":")
-		if len(parts) != 2 {
-			return
-		}
-
 		intelXHost := parts[0]
 		intelXKey := parts[1]
 
-		if intelXKey == "" || intelXHost == "" {
-			return
-		}
-
 		searchReqURL := fmt.Sprintf("https://%s/phonebook/search?k=%s", intelXHost, intelXKey)
 		searchReqHeaders := map[string]string{
 			"Content-Type": "application/json",
@@ -98,7 +111,7 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		var searchRes *http.Response
 
-		searchRes, err = httpclient.Post(searchReqURL, "", searchReqHeaders, bytes.NewBuffer(searchReqBodyBytes))
+		searchRes, err = httpclient.Post(ctx, searchReqURL, "", searchReqHeaders, bytes.NewBuffer(searchReqBodyBytes))
 		if err != nil {
 			result := sources.Result{
 				Type:   sources.Error,
@@ -134,10 +147,35 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 		getResultsReqURL := fmt.Sprintf("https://%s/phonebook/search/result?k=%s&id=%s&limit=10000", intelXHost, intelXKey, searchResData.ID)
 		status := 0
 
-		for status == 0 || status == 3 {
+		// 0: results, more may follow | 1: done | 2: search not found | 3: no results yet
+		for attempt := 0; status == 0 || status == 3; attempt++ {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if attempt >= maxPollAttempts {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  fmt.Errorf("search results not complete after %d attempts", maxPollAttempts),
+				}
+
+				results <- result
+
+				return
+			}
+
+			if status == 3 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(pollInterval):
+				}
+			}
+
 			var getResultsRes *http.Response
 
-			getResultsRes, err = httpclient.Get(getResultsReqURL, "", nil)
+			getResultsRes, err = httpclient.Get(ctx, getResultsReqURL, "", nil)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -186,7 +224,7 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 					results <- result
 
-					return
+					continue
 				}
 
 				parsedURL.Path = strings.Split(parsedURL.Path, ":")[0]
@@ -194,6 +232,8 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 				URL = parsedURL.String()
 
 				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, source.Name(), URL)
+
 					continue
 				}
 
@@ -0,0 +1,34 @@
+package sources
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// DefaultRateLimitJitter is the maximum delay added after rate limited waits
+// when the configuration doesn't specify one.
+const DefaultRateLimitJitter = 250 * time.Millisecond
+
+// Jitter waits, after a rate limiter's Wait, a random delay of up to
+// RateLimitJitter, spreading the requests of goroutines released together.
+// It returns early once ctx is done.
+func (configuration *Configuration) Jitter(ctx context.Context) {
+	jitter := configuration.RateLimitJitter
+
+	if jitter == 0 {
+		jitter = DefaultRateLimitJitter
+	}
+
+	if jitter < 0 {
+		return
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(jitter) + 1)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
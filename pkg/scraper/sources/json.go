@@ -0,0 +1,52 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSON returns the JSON encoding of the result: its source and value, plus
+// the capture's metadata when available.
+func (result Result) JSON() (data []byte, err error) {
+	buffer := &bytes.Buffer{}
+
+	if err = NewJSONLinesWriter(buffer).Write(result); err != nil {
+		return
+	}
+
+	data = bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+
+	return
+}
+
+// JSONLinesWriter writes results as JSON lines, one result per line. It is
+// safe for concurrent use.
+type JSONLinesWriter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// Write writes result as a single JSON line.
+func (writer *JSONLinesWriter) Write(result Result) (err error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	err = writer.encoder.Encode(result)
+
+	return
+}
+
+// NewJSONLinesWriter returns a JSONLinesWriter writing to w.
+func NewJSONLinesWriter(w io.Writer) (writer *JSONLinesWriter) {
+	encoder := json.NewEncoder(w)
+
+	encoder.SetEscapeHTML(false)
+
+	writer = &JSONLinesWriter{
+		encoder: encoder,
+	}
+
+	return
+}
@@ -0,0 +1,27 @@
+package sources
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ReadKnownURLs reads URLs, one per line, e.g. of a previous run's output, for
+// Configuration.KnownURLs. Blank lines are skipped.
+func ReadKnownURLs(reader io.Reader) (URLs []string, err error) {
+	scanner := bufio.NewScanner(reader)
+
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), MaxLineSize)
+
+	for scanner.Scan() {
+		URL := strings.TrimSpace(scanner.Text())
+
+		if URL != "" {
+			URLs = append(URLs, URL)
+		}
+	}
+
+	err = scanner.Err()
+
+	return
+}
@@ -0,0 +1,27 @@
+package sources
+
+// Logger is the logger sources trace their work with. Messages are formatted
+// as with fmt.Printf.
+type Logger interface {
+	Debug(format string, args ...interface{})
+	Info(format string, args ...interface{})
+	Warn(format string, args ...interface{})
+	Error(format string, args ...interface{})
+}
+
+// NopLogger is a Logger discarding every message.
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, ...interface{}) {}
+func (NopLogger) Info(string, ...interface{})  {}
+func (NopLogger) Warn(string, ...interface{})  {}
+func (NopLogger) Error(string, ...interface{}) {}
+
+// Log returns the configuration's logger, NopLogger if not set.
+func (configuration *Configuration) Log() Logger {
+	if configuration.Logger != nil {
+		return configuration.Logger
+	}
+
+	return NopLogger{}
+}
@@ -1,10 +1,12 @@
 package otx
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
+	"github.com/hueristiq/hqgolimit"
 	"github.com/hueristiq/hqgourl"
 	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
@@ -34,7 +36,17 @@ type getURLsResponse struct {
 
 type Source struct{}
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
+func init() {
+	sources.RegisterSource("otx", func() sources.Source {
+		return &Source{}
+	})
+}
+
+var limiter = hqgolimit.New(&hqgolimit.Options{
+	RequestsPerMinute: 30,
+})
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
 	results := make(chan sources.Result)
 
 	go func() {
@@ -54,13 +66,21 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 		}
 
 		for page := 1; ; page++ {
+			if ctx.Err() != nil {
+				return
+			}
+
 			getURLsReqURL := fmt.Sprintf("https://otx.alienvault.com/api/v1/indicators/domain/%s/url_list?limit=100&page=%d", parseURL.ETLDPlusOne, page)
 
 			var err error
 
+			limiter.Wait()
+
+			config.Jitter(ctx)
+
 			var getURLsRes *http.Response
 
-			getURLsRes, err = httpclient.SimpleGet(getURLsReqURL)
+			getURLsRes, err = httpclient.SimpleGet(ctx, getURLsReqURL)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -93,10 +113,17 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 			getURLsRes.Body.Close()
 
+			// domains without indicator data have an empty list
+			if len(getURLsResData.URLList) == 0 {
+				break
+			}
+
 			for _, item := range getURLsResData.URLList {
 				URL := item.URL
 
 				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, source.Name(), URL)
+
 					continue
 				}
 
@@ -0,0 +1,55 @@
+package sources
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	registry   = map[string]func() Source{}
+	registryMu sync.RWMutex
+)
+
+// RegisterSource makes a source available under name. Registering a name
+// twice replaces the previous constructor, letting custom sources override
+// the built-in ones.
+func RegisterSource(name string, constructor func() Source) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = constructor
+}
+
+// NewSource returns a new instance of the source registered under name, or
+// false if there is none.
+func NewSource(name string) (source Source, ok bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var constructor func() Source
+
+	constructor, ok = registry[name]
+	if !ok {
+		return
+	}
+
+	source = constructor()
+
+	return
+}
+
+// ListSources returns the sorted names of the registered sources.
+func ListSources() (names []string) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names = make([]string, 0, len(registry))
+
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
@@ -0,0 +1,206 @@
+package shodan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hueristiq/hqgolimit"
+	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+type searchResponse struct {
+	Matches []struct {
+		IPStr     string          `json:"ip_str"`
+		Port      int             `json:"port"`
+		Hostnames []string        `json:"hostnames"`
+		SSL       json.RawMessage `json:"ssl"`
+		HTTP      *struct {
+			Host     string `json:"host"`
+			Location string `json:"location"`
+		} `json:"http"`
+	} `json:"matches"`
+	Total int    `json:"total"`
+	Error string `json:"error"`
+}
+
+type Source struct{}
+
+func init() {
+	sources.RegisterSource("shodan", func() sources.Source {
+		return &Source{}
+	})
+}
+
+// the API allows a request per second.
+var limiter = hqgolimit.New(&hqgolimit.Options{
+	RequestsPerMinute: 60,
+})
+
+// pageSize is the number of matches per page of the host search.
+const pageSize = 100
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
+	results := make(chan sources.Result)
+
+	go func() {
+		defer close(results)
+
+		var err error
+
+		var key string
+
+		key, err = sources.PickKey(source.Name(), config.Keys.Shodan)
+		if err != nil {
+			return
+		}
+
+		for page := 1; ; page++ {
+			if ctx.Err() != nil {
+				return
+			}
+
+			searchReqURL := fmt.Sprintf("https://api.shodan.io/shodan/host/search?query=hostname:%s&key=%s&page=%d", domain, key, page)
+
+			limiter.Wait()
+
+			config.Jitter(ctx)
+
+			var searchRes *http.Response
+
+			searchRes, err = httpclient.SimpleGet(ctx, searchReqURL)
+			if err != nil {
+				// plan limited keys, e.g. without search access, are
+				// answered with an error message.
+				if message := getErrorMessage(searchRes); message != "" {
+					err = fmt.Errorf("%w: %s", err, message)
+				}
+
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				httpclient.DiscardResponse(searchRes)
+
+				return
+			}
+
+			var searchResData searchResponse
+
+			if err = json.NewDecoder(searchRes.Body).Decode(&searchResData); err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				searchRes.Body.Close()
+
+				return
+			}
+
+			searchRes.Body.Close()
+
+			if searchResData.Error != "" {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  errors.New(searchResData.Error),
+				}
+
+				results <- result
+
+				return
+			}
+
+			for _, match := range searchResData.Matches {
+				if match.HTTP == nil {
+					continue
+				}
+
+				scheme := "http"
+
+				if len(match.SSL) > 0 && string(match.SSL) != "null" {
+					scheme = "https"
+				}
+
+				hosts := match.Hostnames
+
+				if match.HTTP.Host != "" {
+					hosts = []string{match.HTTP.Host}
+				}
+
+				for _, host := range hosts {
+					URL := formatURL(scheme, host, match.Port, match.HTTP.Location)
+
+					if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+						config.OutOfScope(results, source.Name(), URL)
+
+						continue
+					}
+
+					result := sources.Result{
+						Type:   sources.URL,
+						Source: source.Name(),
+						Value:  URL,
+					}
+
+					results <- result
+				}
+			}
+
+			if len(searchResData.Matches) < pageSize || page*pageSize >= searchResData.Total {
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+// formatURL returns the URL of an HTTP service, the port omitted if it's the
+// scheme's default one.
+func formatURL(scheme, host string, port int, location string) (URL string) {
+	URL = scheme + "://" + host
+
+	if !(scheme == "http" && port == 80) && !(scheme == "https" && port == 443) && port > 0 {
+		URL += ":" + strconv.Itoa(port)
+	}
+
+	if location == "" {
+		location = "/"
+	}
+
+	URL += location
+
+	return
+}
+
+// getErrorMessage returns the message of an error response, if any.
+func getErrorMessage(res *http.Response) (message string) {
+	if res == nil || res.Body == nil {
+		return
+	}
+
+	var errorResData searchResponse
+
+	if err := json.NewDecoder(res.Body).Decode(&errorResData); err == nil {
+		message = errorResData.Error
+	}
+
+	return
+}
+
+func (source *Source) Name() string {
+	return "shodan"
+}
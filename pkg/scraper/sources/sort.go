@@ -0,0 +1,35 @@
+package sources
+
+import "sort"
+
+// Orderings of URL results, for Configuration.SortBy.
+const (
+	// SortByNone emits URLs as they're found.
+	SortByNone = "none"
+	// SortByURL emits URLs, once every source is done, in lexical order.
+	SortByURL = "url"
+	// SortByTimestamp emits URLs, once every source is done, from the oldest
+	// capture, URLs without timestamp first, then in lexical order.
+	SortByTimestamp = "timestamp"
+)
+
+// SortBys are the supported orderings of URL results.
+var SortBys = []string{SortByNone, SortByURL, SortByTimestamp}
+
+// SortResults sorts results, stably, in the given ordering, one of SortBys.
+func SortResults(results []Result, by string) {
+	switch by {
+	case SortByURL:
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Value < results[j].Value
+		})
+	case SortByTimestamp:
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].Timestamp != results[j].Timestamp {
+				return results[i].Timestamp < results[j].Timestamp
+			}
+
+			return results[i].Value < results[j].Value
+		})
+	}
+}
@@ -1,33 +1,326 @@
 package sources
 
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
 type Source interface {
-	// Run takes in configuration which includes keys/tokens and other stuff,
-	// and domain as arguments.
-	Run(config *Configuration, domain string) <-chan Result
+	// Run takes in a context, used to cancel the run, configuration which
+	// includes keys/tokens and other stuff, and domain as arguments. Both
+	// URLs and errors are sent on the returned channel, which is closed once
+	// the source is done.
+	Run(ctx context.Context, config *Configuration, domain string) <-chan Result
 	// Name returns the name of the source.
 	Name() string
 }
 
+// WildcardSource is a Source that translates wildcard domain patterns, e.g.
+// `*.gov`, into its own queries. Other sources are run against the pattern's
+// domain, with subdomains, and skipped, with ErrWildcardUnsupported, for bare
+// suffixes, e.g. `gov`, they can't be queried for.
+type WildcardSource interface {
+	Source
+	// RunWildcard is Run against every subdomain of suffix, the domain of a
+	// wildcard pattern, which may be a single label.
+	RunWildcard(ctx context.Context, config *Configuration, suffix string) <-chan Result
+}
+
 type Configuration struct {
-	IncludeSubdomains  bool
+	IncludeSubdomains bool
+	// EmitOutOfScope emits, as OutOfScope results, the URLs sources found
+	// out of scope, e.g. third-party APIs the target links to, instead of
+	// dropping them.
+	EmitOutOfScope bool
+	// FilterPattern and MatchPattern are regular expressions URLs are
+	// dropped, or kept, by, compiled by scraper.New. The filter takes
+	// precedence over the match.
+	FilterPattern string
+	MatchPattern  string
+	// ExcludeHosts are hosts, optionally with wildcards, e.g.
+	// `*.cloudfront.net`, whose URLs are dropped even when in scope.
+	ExcludeHosts       []string
 	Keys               Keys
 	ParseWaybackRobots bool
 	ParseWaybackSource bool
+	WaybackFrom        string
+	WaybackTo          string
+	// StateFile is the path of a file the wayback source saves, per domain,
+	// the most recent capture timestamp listed to, so that the next runs
+	// only list captures more recent than it. Timestamps are only known
+	// without WaybackSkipMetadata.
+	StateFile string
+	// WaybackStatusCodes restricts wayback URLs to the given archived status
+	// codes. Negative values exclude the status code instead, e.g. -404.
+	WaybackStatusCodes []int
+	// WaybackRateLimit is the maximum number of requests per minute made to
+	// archive.org by a wayback source.
+	WaybackRateLimit int
+	// RateLimitJitter is the maximum random delay added after waiting on a
+	// rate limiter, so that requests released together are spread. If not
+	// set, DefaultRateLimitJitter is used. Negative values disable it.
+	RateLimitJitter time.Duration
+	// WaybackSkipMetadata lists wayback URLs without their timestamp, status
+	// code and MIME type, which makes for a lighter listing.
+	WaybackSkipMetadata bool
+	// WaybackMatchType is the CDX matchType of the wayback URLs listing, one
+	// of WaybackMatchTypes. If not set, the domain and its www host are
+	// matched as hosts, or the domain as a prefix with a leading wildcard to
+	// include subdomains.
+	WaybackMatchType string
+	// WaybackCollapse is the CDX collapse of the URLs listing: urlkey, one
+	// URL per canonical form, merging those differing only in case or
+	// default port, a field, e.g. original or digest, optionally on its N
+	// leading characters, e.g. timestamp:8, or none. Distinct originals,
+	// and even more so none, list far more URLs. If not set, urlkey is used.
+	WaybackCollapse string
+	// WaybackLatestOnly lists, of each URL canonical form (urlkey), only its
+	// most recent capture, with its timestamp. CDX collapsing keeps the
+	// oldest capture, so the listing isn't collapsed, unless WaybackCollapse
+	// is set, and is filtered on timestamps instead. It is ignored with
+	// WaybackSkipMetadata.
+	WaybackLatestOnly bool
+	// WaybackSnapshotsCollapse is the CDX collapse of the snapshots listed
+	// for source: digest, urlkey, timestamp:N (one snapshot per N leading
+	// timestamp digits, e.g. timestamp:8 for one per day) or none. If not
+	// set, digest is used, i.e. one snapshot per distinct content.
+	WaybackSnapshotsCollapse string
+	// WaybackAvailability parses, for source, only the snapshot of each URL
+	// returned by the availability API instead of all of them, falling back
+	// to all of them if it has none.
+	WaybackAvailability bool
+	// SkipSourceExtensions are the extensions of URLs whose wayback snapshots
+	// are not parsed, for robots or source. The URLs are still emitted. If
+	// nil, wayback.DefaultSkipSourceExtensions is used.
+	SkipSourceExtensions []string
+	// ParseMIMETypes are the archived MIME types, optionally with wildcards,
+	// e.g. `text/*`, of URLs whose wayback snapshots are parsed for source.
+	// The URLs of other types are still emitted. If nil,
+	// wayback.DefaultParseMIMETypes is used. URLs whose type is unknown, e.g.
+	// with WaybackSkipMetadata, are parsed.
+	ParseMIMETypes []string
+	// WaybackBaseURL is the base URL of the CDX, availability and replay
+	// server, for self-hosted mirrors. If not set, web.archive.org is used.
+	WaybackBaseURL string
+	// WaybackReplayModifier is the replay mode snapshots are first fetched
+	// in, for source, one of WaybackReplayModifiers: `if_`, whose links are
+	// rewritten to archived captures, unwrapped back to their originals by
+	// the extractors, or `id_`, the original bytes, links untouched. The
+	// other modes are fallen back to. If not set, `if_` is used.
+	WaybackReplayModifier string
+	// Concurrency is the maximum number of wayback snapshots listed, or
+	// fetched and parsed, at once, by robots, sitemap and source parsing.
+	Concurrency int
+	// Timeout is the timeout, in seconds, of requests, set by scraper.New
+	// on the HTTP client. If not set, httpclient's default is used.
+	Timeout int
+	// CommonCrawlIndexes is the number of most recent commoncrawl indexes to
+	// query. If not set, the first index of each of the last 5 years is queried.
+	CommonCrawlIndexes int
+	// SortQueryParams sorts query parameters when normalizing URLs for
+	// deduplication, treating URLs differing only in parameter order as one.
+	SortQueryParams bool
+	// AllowedSchemes are the schemes of URLs emitted, others, e.g. mailto: or
+	// javascript:, being dropped, as are URLs without host. If nil,
+	// DefaultAllowedSchemes is used.
+	AllowedSchemes []string
+	// UniquePaths deduplicates URLs on their scheme, host and path, emitting
+	// only the first URL seen of each path, whatever its query.
+	UniquePaths bool
+	// KnownURLs are URLs already known, e.g. processed by a previous run,
+	// taken for seen before scrapes start, so that only new URLs are emitted.
+	// They're deduplicated on as URLs found are, normalized.
+	KnownURLs []string
+	// SubdomainsOnly outputs the unique hosts of the URLs found, instead of
+	// the URLs themselves.
+	SubdomainsOnly bool
+	// CountOnly reports, once each source is done, the number of URLs it
+	// found as a Count result, instead of the URLs themselves. Wayback
+	// snapshots aren't parsed, for robots or source, in this mode.
+	CountOnly bool
+	// DedupMode is how URLs seen are remembered, for deduplication, one of
+	// DedupModes. If not set, DedupModeExact is used. DedupModeBloom keeps
+	// memory flat on huge domains at the cost of dropping, at
+	// DedupFalsePositiveRate, some URLs never seen before.
+	DedupMode string
+	// DedupCapacity is the number of URLs the bloom filter is sized for. If
+	// not set, DefaultDedupCapacity is used.
+	DedupCapacity int
+	// DedupFalsePositiveRate is the rate at which the bloom filter, when
+	// holding DedupCapacity URLs, takes new URLs for seen ones. If not set,
+	// DefaultDedupFalsePositiveRate is used.
+	DedupFalsePositiveRate float64
+	// CollapseParamValues replaces query parameter values with a placeholder,
+	// emitting one URL per unique set of parameters.
+	CollapseParamValues bool
+	// MaxResults is the maximum number of distinct URLs emitted, across all
+	// sources, after which they are stopped. If not set, there is no limit.
+	MaxResults int
+	// MaxResultsPerSource is the maximum number of URLs emitted by each
+	// source, after which it is stopped. If not set, there is no limit.
+	MaxResultsPerSource int
+	// SortBy, one of SortBys, collects the URLs found and emits them, sorted,
+	// once every source is done, for runs to be diffed, at the cost of
+	// streaming. If not set, URLs are emitted as they're found.
+	SortBy string
+	// Cache caches the wayback CDX and availability responses, not the
+	// snapshots', in memory or, if CacheDir is set, on disk. Cached responses
+	// stay fresh for CacheTTL and are refetched, and replaced, regardless
+	// with RefreshCache.
+	Cache        bool
+	CacheDir     string
+	CacheTTL     time.Duration
+	RefreshCache bool
+	// Transform, if set, is given every URL found, after the sources' scope
+	// checks and before deduplication and filters, to rewrite it, e.g. to
+	// strip session parameters, or drop it, by returning false.
+	Transform func(result Result) (transformed Result, keep bool)
+	// OnProgress, if set, is called with the progress of scraping a domain
+	// every ProgressInterval, DefaultProgressInterval if not set, and once
+	// done. Requests and Bytes count every request made meanwhile, by any
+	// concurrent scrape.
+	OnProgress       func(Stats)
+	ProgressInterval time.Duration
+	// Logger traces the sources' requests and decisions, discarded if not set.
+	Logger Logger
 }
 
 type Keys struct {
-	Bevigil []string `yaml:"bevigil"`
-	GitHub  []string `yaml:"github"`
-	Intelx  []string `yaml:"intelx"`
-	URLScan []string `yaml:"urlscan"`
+	Bevigil    []string `yaml:"bevigil"`
+	GitHub     []string `yaml:"github"`
+	Intelx     []string `yaml:"intelx"`
+	Shodan     []string `yaml:"shodan"`
+	URLScan    []string `yaml:"urlscan"`
+	VirusTotal []string `yaml:"virustotal"`
+}
+
+// WaybackMatchTypes are the supported CDX matchType values.
+var WaybackMatchTypes = []string{"exact", "prefix", "host", "domain"}
+
+// WaybackReplayModifiers are the supported wayback replay modifiers.
+var WaybackReplayModifiers = []string{"if_", "id_"}
+
+// waybackCollapseRegex matches the supported CDX collapse values: none, or a
+// field, optionally on its N leading characters.
+var waybackCollapseRegex = regexp.MustCompile(`^(none|(urlkey|timestamp|original|mimetype|statuscode|digest|length)(:[1-9][0-9]?)?)$`)
+
+// Validate checks the configuration for invalid values.
+func (configuration *Configuration) Validate() (err error) {
+	if configuration.WaybackMatchType != "" {
+		valid := false
+
+		for _, matchType := range WaybackMatchTypes {
+			if configuration.WaybackMatchType == matchType {
+				valid = true
+
+				break
+			}
+		}
+
+		if !valid {
+			err = fmt.Errorf("invalid wayback match type %q, expected one of: %s", configuration.WaybackMatchType, strings.Join(WaybackMatchTypes, ", "))
+
+			return
+		}
+	}
+
+	if configuration.DedupMode != "" && configuration.DedupMode != DedupModeExact && configuration.DedupMode != DedupModeBloom {
+		err = fmt.Errorf("invalid dedup mode %q, expected one of: %s", configuration.DedupMode, strings.Join(DedupModes, ", "))
+
+		return
+	}
+
+	if configuration.SortBy != "" && configuration.SortBy != SortByNone && configuration.SortBy != SortByURL && configuration.SortBy != SortByTimestamp {
+		err = fmt.Errorf("invalid sort by %q, expected one of: %s", configuration.SortBy, strings.Join(SortBys, ", "))
+
+		return
+	}
+
+	if configuration.DedupFalsePositiveRate < 0 || configuration.DedupFalsePositiveRate >= 1 {
+		err = fmt.Errorf("invalid dedup false positive rate %v, expected a value between 0 and 1", configuration.DedupFalsePositiveRate)
+
+		return
+	}
+
+	if configuration.WaybackCollapse != "" && !waybackCollapseRegex.MatchString(configuration.WaybackCollapse) {
+		err = fmt.Errorf("invalid wayback collapse %q, expected a CDX field, e.g. urlkey, digest or timestamp:N, or none", configuration.WaybackCollapse)
+
+		return
+	}
+
+	if configuration.WaybackSnapshotsCollapse != "" && !waybackCollapseRegex.MatchString(configuration.WaybackSnapshotsCollapse) {
+		err = fmt.Errorf("invalid wayback snapshots collapse %q, expected a CDX field, e.g. digest, urlkey or timestamp:N, or none", configuration.WaybackSnapshotsCollapse)
+
+		return
+	}
+
+	if configuration.WaybackReplayModifier != "" && configuration.WaybackReplayModifier != "if_" && configuration.WaybackReplayModifier != "id_" {
+		err = fmt.Errorf("invalid wayback replay modifier %q, expected one of: %s", configuration.WaybackReplayModifier, strings.Join(WaybackReplayModifiers, ", "))
+
+		return
+	}
+
+	if _, compileErr := regexp.Compile(configuration.FilterPattern); compileErr != nil {
+		err = fmt.Errorf("invalid filter pattern: %w", compileErr)
+
+		return
+	}
+
+	if _, compileErr := regexp.Compile(configuration.MatchPattern); compileErr != nil {
+		err = fmt.Errorf("invalid match pattern: %w", compileErr)
+
+		return
+	}
+
+	if configuration.WaybackBaseURL != "" {
+		parsedURL, parseErr := url.Parse(configuration.WaybackBaseURL)
+		if parseErr != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			err = fmt.Errorf("invalid wayback base URL %q, expected an absolute HTTP(S) URL", configuration.WaybackBaseURL)
+
+			return
+		}
+	}
+
+	return
+}
+
+// OutOfScope sends URL, found out of scope by source, to results as an
+// OutOfScope result, with EmitOutOfScope.
+func (configuration *Configuration) OutOfScope(results chan<- Result, source, URL string) {
+	if !configuration.EmitOutOfScope || URL == "" {
+		return
+	}
+
+	results <- Result{
+		Type:   OutOfScope,
+		Source: source,
+		Value:  URL,
+	}
 }
 
-// Result is a result structure returned by a source.
+// Result is a result structure returned by a source. Depending on its Type,
+// either Value holds a URL or Error holds a failure the source ran into.
 type Result struct {
-	Type   ResultType
-	Source string
-	Value  string
-	Error  error
+	Type ResultType `json:"-"`
+	// Domain is the target domain the result was found for.
+	Domain string `json:"domain,omitempty"`
+	Source string `json:"source"`
+	Value  string `json:"value"`
+	Error  error  `json:"-"`
+	// Timestamp, StatusCode and MIMEType are the archived capture's metadata,
+	// set only by sources that have it.
+	Timestamp  string `json:"timestamp,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	MIMEType   string `json:"mime_type,omitempty"`
+	// ReplayURL is the URL of the archived capture, set only by the wayback
+	// source, for results with a Timestamp.
+	ReplayURL string `json:"replay_url,omitempty"`
+	// Count is the number of URLs found by the source, for Count results.
+	Count int64 `json:"count,omitempty"`
 }
 
 // ResultType is the type of result returned by the source.
@@ -37,14 +330,19 @@ type ResultType int
 const (
 	URL ResultType = iota
 	Error
+	Count
+	OutOfScope
 )
 
 var List = []string{
+	"archivetoday",
 	"bevigil",
 	"commoncrawl",
 	"github",
 	"intelx",
 	"otx",
+	"shodan",
 	"urlscan",
+	"virustotal",
 	"wayback",
 }
@@ -0,0 +1,63 @@
+package sources
+
+import "testing"
+
+func TestValidateWaybackReplayModifier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		modifier string
+		wantErr  bool
+	}{
+		{"", false},
+		{"if_", false},
+		{"id_", false},
+		{"js_", true},
+		{"IF_", true},
+		{"if", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.modifier, func(t *testing.T) {
+			t.Parallel()
+
+			config := &Configuration{WaybackReplayModifier: tt.modifier}
+
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		filterPattern string
+		matchPattern  string
+		wantErr       bool
+	}{
+		{"unset", "", "", false},
+		{"valid", `logout`, `api/`, false},
+		{"invalid filter", `(logout`, "", true},
+		{"invalid match", "", `api/[`, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := &Configuration{FilterPattern: tt.filterPattern, MatchPattern: tt.matchPattern}
+
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
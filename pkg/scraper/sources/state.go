@@ -0,0 +1,94 @@
+package sources
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stateMutex serializes the state file's read-modify-write cycles, domains
+// being scraped concurrently.
+var stateMutex sync.Mutex
+
+// LoadHighWaterMark returns the timestamp saved for key, e.g. a domain, in
+// the state file at path, or an empty string if there's none.
+func LoadHighWaterMark(path, key string) (timestamp string, err error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	var state map[string]string
+
+	state, err = readState(path)
+	if err != nil {
+		return
+	}
+
+	timestamp = state[key]
+
+	return
+}
+
+// SaveHighWaterMark saves timestamp for key, e.g. a domain, in the state file
+// at path, unless the timestamp saved already is more recent.
+func SaveHighWaterMark(path, key, timestamp string) (err error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	var state map[string]string
+
+	state, err = readState(path)
+	if err != nil {
+		return
+	}
+
+	if state[key] >= timestamp {
+		return
+	}
+
+	state[key] = timestamp
+
+	var data []byte
+
+	data, err = json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return
+	}
+
+	if directory := filepath.Dir(path); directory != "" {
+		if err = os.MkdirAll(directory, os.ModePerm); err != nil {
+			return
+		}
+	}
+
+	// written aside then renamed, not to leave a truncated state behind.
+	temporary := path + ".tmp"
+
+	if err = os.WriteFile(temporary, data, 0o644); err != nil {
+		return
+	}
+
+	err = os.Rename(temporary, path)
+
+	return
+}
+
+func readState(path string) (state map[string]string, err error) {
+	state = map[string]string{}
+
+	var data []byte
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+
+		return
+	}
+
+	err = json.Unmarshal(data, &state)
+
+	return
+}
@@ -0,0 +1,34 @@
+package sources
+
+import "time"
+
+// DefaultProgressInterval is how often progress is reported when the
+// configuration doesn't specify it.
+const DefaultProgressInterval = time.Second
+
+// Stats is the progress of scraping a domain.
+type Stats struct {
+	// URLs is the number of URLs emitted.
+	URLs int64
+	// Errors is the number of errors emitted.
+	Errors int64
+	// Requests is the number of HTTP requests made.
+	Requests int64
+	// Bytes is the number of response body bytes downloaded, after
+	// decompression.
+	Bytes int64
+	// WireBytes is the number of response body bytes transferred, before
+	// decompression.
+	WireBytes int64
+	// Elapsed is the time spent so far.
+	Elapsed time.Duration
+}
+
+// URLsPerSecond is the average rate URLs were emitted at.
+func (stats Stats) URLsPerSecond() float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(stats.URLs) / stats.Elapsed.Seconds()
+}
@@ -0,0 +1,58 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Format is the format results are streamed in, by StreamTo.
+type Format string
+
+// Formats results are streamed in.
+const (
+	// FormatText is one URL per line.
+	FormatText Format = "text"
+	// FormatJSONL is one JSON result per line, as Result.JSON encodes them.
+	FormatJSONL Format = "jsonl"
+)
+
+// ErrUnknownFormat is returned, wrapped, by StreamTo for unsupported formats.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// StreamTo writes the URL results received from results to w, in format,
+// until results is closed. Other results are dropped. Writers with a Flush
+// method, e.g. bufio.Writer, are flushed after each URL, for output to show
+// as results arrive. On a write error, results is drained, not to block its
+// producers, and the error returned.
+func StreamTo(w io.Writer, results <-chan Result, format Format) (err error) {
+	var JSONLines *JSONLinesWriter
+
+	switch format {
+	case FormatText:
+	case FormatJSONL:
+		JSONLines = NewJSONLinesWriter(w)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	flusher, _ := w.(interface{ Flush() error })
+
+	for result := range results {
+		if err != nil || result.Type != URL {
+			continue
+		}
+
+		if JSONLines != nil {
+			err = JSONLines.Write(result)
+		} else {
+			_, err = fmt.Fprintln(w, result.Value)
+		}
+
+		if err == nil && flusher != nil {
+			err = flusher.Flush()
+		}
+	}
+
+	return
+}
@@ -0,0 +1,84 @@
+package sources
+
+import (
+	"sort"
+	"sync"
+)
+
+// HostSummary tallies URL results per host, as a summary of a scrape, e.g. of
+// the subdomains with the most archived URLs. It is safe for concurrent use.
+type HostSummary struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewHostSummary returns an empty HostSummary.
+func NewHostSummary() *HostSummary {
+	return &HostSummary{
+		counts: make(map[string]int),
+	}
+}
+
+// Add tallies result, if a URL with a host.
+func (summary *HostSummary) Add(result Result) {
+	if result.Type != URL {
+		return
+	}
+
+	host := ExtractHost(result.Value)
+	if host == "" {
+		return
+	}
+
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	summary.counts[host]++
+}
+
+// Counts returns the number of URLs tallied per host.
+func (summary *HostSummary) Counts() (counts map[string]int) {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	counts = make(map[string]int, len(summary.counts))
+
+	for host, count := range summary.counts {
+		counts[host] = count
+	}
+
+	return
+}
+
+// Hosts returns the hosts tallied, those with the most URLs first, then in
+// lexical order.
+func (summary *HostSummary) Hosts() (hosts []string) {
+	counts := summary.Counts()
+
+	hosts = make([]string, 0, len(counts))
+
+	for host := range counts {
+		hosts = append(hosts, host)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if counts[hosts[i]] != counts[hosts[j]] {
+			return counts[hosts[i]] > counts[hosts[j]]
+		}
+
+		return hosts[i] < hosts[j]
+	})
+
+	return
+}
+
+// SummarizeByHost consumes results, returning the number of URLs per host.
+func SummarizeByHost(results <-chan Result) (counts map[string]int) {
+	summary := NewHostSummary()
+
+	for result := range results {
+		summary.Add(result)
+	}
+
+	return summary.Counts()
+}
@@ -1,11 +1,13 @@
 package urlscan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/hueristiq/hqgohttp/status"
 	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
 	"github.com/spf13/cast"
@@ -19,6 +21,9 @@ type searchResponse struct {
 			URL      string `json:"url"`
 			Status   string `json:"status"`
 		} `json:"page"`
+		Task struct {
+			URL string `json:"url"`
+		} `json:"task"`
 		Sort []interface{} `json:"sort"`
 	} `json:"results"`
 	Status  int  `json:"status"`
@@ -29,7 +34,13 @@ type searchResponse struct {
 
 type Source struct{}
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
+func init() {
+	sources.RegisterSource("urlscan", func() sources.Source {
+		return &Source{}
+	})
+}
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
 	results := make(chan sources.Result)
 
 	go func() {
@@ -39,30 +50,24 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 		var key string
 
-		key, err = sources.PickRandom(config.Keys.URLScan)
-		if err != nil {
-			result := sources.Result{
-				Type:   sources.Error,
-				Source: source.Name(),
-				Error:  err,
-			}
-
-			results <- result
-
-			return
-		}
+		// the key is optional, without it the anonymous tier is used.
+		key, err = sources.PickKey(source.Name(), config.Keys.URLScan)
 
 		searchReqHeaders := map[string]string{
 			"Content-Type": "application/json",
 		}
 
-		if key != "" {
+		if err == nil {
 			searchReqHeaders["API-Key"] = key
 		}
 
 		var after string
 
 		for {
+			if ctx.Err() != nil {
+				return
+			}
+
 			searchReqURL := fmt.Sprintf("https://urlscan.io/api/v1/search/?q=domain:%s&size=100", domain)
 
 			if after != "" {
@@ -71,7 +76,7 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 			var searchRes *http.Response
 
-			searchRes, err = httpclient.Get(searchReqURL, "", searchReqHeaders)
+			searchRes, err = httpclient.Get(ctx, searchReqURL, "", searchReqHeaders)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -104,24 +109,36 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 			searchRes.Body.Close()
 
-			if searchResData.Status == 429 {
+			// 429 responses are retried by the HTTP client, this is returned
+			// once it gives up.
+			if searchResData.Status == status.TooManyRequests {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  fmt.Errorf("rate limit exceeded"),
+				}
+
+				results <- result
+
 				break
 			}
 
-			for _, result := range searchResData.Results {
-				URL := result.Page.URL
+			for _, item := range searchResData.Results {
+				for _, URL := range []string{item.Page.URL, item.Task.URL} {
+					if URL == "" || !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+						config.OutOfScope(results, source.Name(), URL)
 
-				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
-					continue
-				}
+						continue
+					}
 
-				result := sources.Result{
-					Type:   sources.URL,
-					Source: source.Name(),
-					Value:  URL,
-				}
+					result := sources.Result{
+						Type:   sources.URL,
+						Source: source.Name(),
+						Value:  URL,
+					}
 
-				results <- result
+					results <- result
+				}
 			}
 
 			if !searchResData.HasMore {
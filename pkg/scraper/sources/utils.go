@@ -2,12 +2,18 @@ package sources
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"net/url"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
-	"github.com/hueristiq/hqgourl"
+	"golang.org/x/net/idna"
 )
 
 func PickRandom[T any](v []T) (picked T, err error) {
@@ -35,26 +41,311 @@ func PickRandom[T any](v []T) (picked T, err error) {
 	return
 }
 
+// MaxLineSize is the maximum size of a line scanned from a response body.
+// It is well above bufio's 64KB default, as archived URLs can get very long.
+const MaxLineSize = 10 * 1024 * 1024
+
+// ErrNoKey is returned by PickKey when a source has no key configured.
+var ErrNoKey = errors.New("no key available")
+
+var keyCursors = struct {
+	sync.Mutex
+	cursors map[string]int
+}{
+	cursors: map[string]int{},
+}
+
+// PickKey picks the next of a source's keys, round-robin.
+func PickKey(source string, keys []string) (key string, err error) {
+	if len(keys) == 0 {
+		err = ErrNoKey
+
+		return
+	}
+
+	keyCursors.Lock()
+	defer keyCursors.Unlock()
+
+	cursor := keyCursors.cursors[source] % len(keys)
+
+	key = keys[cursor]
+
+	keyCursors.cursors[source] = cursor + 1
+
+	return
+}
+
+// IsInScope reports whether URL's host is domain, or its www. subdomain, or
+// with includeSubdomains, any subdomain of it. Ports and userinfo are ignored.
+// A URL with an IP address host, IPv6 ones bracketed, is only in scope if
+// domain is that same IP address, and the other way round. URLs with a
+// malformed authority aren't.
 func IsInScope(URL, domain string, includeSubdomains bool) (isInScope bool) {
-	parsedURL, err := hqgourl.Parse(URL)
-	if err != nil {
+	host := getHostname(URL)
+	target := getHostname(domain)
+
+	if host == "" || target == "" {
+		return
+	}
+
+	// IP addresses have no subdomains, `1.192.0.2.1` isn't one of 192.0.2.1.
+	if IP, targetIP := net.ParseIP(host), net.ParseIP(target); IP != nil || targetIP != nil {
+		isInScope = IP != nil && IP.Equal(targetIP)
+
+		return
+	}
+
+	if host == target || host == "www."+target {
+		isInScope = true
+
+		return
+	}
+
+	isInScope = includeSubdomains && strings.HasSuffix(host, "."+target)
+
+	return
+}
+
+// MatchHost reports whether URL's host matches one of patterns. Patterns are
+// hosts, optionally with wildcards as in path.Match, e.g. `*.cloudfront.net`
+// matching any of its subdomains, and are compared case-insensitively.
+func MatchHost(URL string, patterns []string) (matches bool) {
+	host := getHostname(URL)
+
+	if host == "" {
+		return
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+		if matched, err := path.Match(pattern, host); err == nil && matched {
+			matches = true
+
+			return
+		}
+	}
+
+	return
+}
+
+// ExtractHost returns the lowercased, punycode encoded, host of URL, without
+// port, or an empty string if it has none.
+func ExtractHost(URL string) (host string) {
+	return getHostname(URL)
+}
+
+// ErrInvalidDomain is returned, wrapped, by NormalizeDomain for inputs that
+// aren't a domain, or a URL of one.
+var ErrInvalidDomain = errors.New("invalid domain")
+
+// domainLabelRegex matches a valid domain name label.
+var domainLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NormalizeDomain returns the domain of input, which may be a URL, e.g.
+// `http://example.com/` or `example.com:443`: its lowercased, punycode
+// encoded, host without scheme, port, path or trailing dot. IP addresses are
+// accepted as they are. Inputs that aren't a domain with at least two labels
+// are rejected with ErrInvalidDomain.
+func NormalizeDomain(input string) (domain string, err error) {
+	return normalizeDomain(input, 2)
+}
+
+// ErrInvalidDomainPattern is returned, wrapped, by NormalizeDomainPattern for
+// wildcard patterns sources can't be queried for, e.g. `api.*.example.com`.
+var ErrInvalidDomainPattern = errors.New("invalid domain pattern")
+
+// ErrWildcardUnsupported is emitted, wrapped, by sources skipped for wildcard
+// patterns they can't be queried for, see WildcardSource.
+var ErrWildcardUnsupported = errors.New("wildcard domain pattern not supported")
+
+// NormalizeDomainPattern is NormalizeDomain for inputs that may also be a
+// leading wildcard pattern, e.g. `*.gov` or `*.example.com`, matching a domain
+// and its subdomains: wildcard is then true, and domain, which may be a single
+// label, e.g. a TLD, the pattern's. Wildcards elsewhere are rejected with
+// ErrInvalidDomainPattern.
+func NormalizeDomainPattern(input string) (domain string, wildcard bool, err error) {
+	input = strings.TrimSpace(input)
+
+	pattern, wildcard := strings.CutPrefix(input, "*.")
+
+	if strings.Contains(pattern, "*") {
+		err = fmt.Errorf("%w: %q, only a leading wildcard, e.g. *.example.com, is supported", ErrInvalidDomainPattern, input)
+
+		return
+	}
+
+	if !wildcard {
+		domain, err = NormalizeDomain(input)
+
+		return
+	}
+
+	domain, err = normalizeDomain(pattern, 1)
+
+	return
+}
+
+// normalizeDomain is NormalizeDomain, for domains of at least minLabels labels.
+func normalizeDomain(input string, minLabels int) (domain string, err error) {
+	input = strings.TrimSpace(input)
+
+	host := getHostname(input)
+
+	if host == "" {
+		err = fmt.Errorf("%w: %q", ErrInvalidDomain, input)
+
+		return
+	}
+
+	if net.ParseIP(host) != nil {
+		domain = host
+
+		return
+	}
+
+	labels := strings.Split(host, ".")
+
+	if len(labels) < minLabels || len(host) > 253 {
+		err = fmt.Errorf("%w: %q, expected a domain, e.g. example.com", ErrInvalidDomain, input)
+
+		return
+	}
+
+	for _, label := range labels {
+		if !domainLabelRegex.MatchString(label) {
+			err = fmt.Errorf("%w: %q, label %q is not a valid domain name label", ErrInvalidDomain, input, label)
+
+			return
+		}
+	}
+
+	domain = host
+
+	return
+}
+
+// getHostname returns the lowercased, punycode encoded, host of URL, without
+// port, brackets or trailing dot. URLs without a scheme are treated as http
+// ones.
+func getHostname(URL string) (hostname string) {
+	// bare IPv6 addresses, e.g. `::1`, don't parse as URLs.
+	if IP := net.ParseIP(strings.Trim(URL, "[]")); IP != nil {
+		hostname = IP.String()
+
 		return
 	}
 
-	parsedDomain, err := hqgourl.Parse(domain)
+	parsedURL, err := url.Parse(URL)
+
+	// URLs without scheme, e.g. `user:pass@example.com/path`, not malformed
+	// ones, e.g. `http://[::1/`, which have no host.
+	if (err != nil || parsedURL.Host == "") && !strings.HasPrefix(URL, "/") && !strings.Contains(URL, "://") {
+		parsedURL, err = url.Parse("http://" + URL)
+	}
+
 	if err != nil {
 		return
 	}
 
-	if parsedURL.ETLDPlusOne != parsedDomain.ETLDPlusOne {
+	hostname = strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+
+	// compare internationalized hosts in their punycode form.
+	if ASCII, err := idna.ToASCII(hostname); err == nil {
+		hostname = ASCII
+	}
+
+	return
+}
+
+// MatchExtension reports whether the URL's path has one of the given
+// extensions. Extensions are compared case-insensitively, with or without
+// their leading dot, and the query and fragment are ignored.
+func MatchExtension(URL string, extensions []string) (matches bool) {
+	URLPath := URL
+
+	if parsedURL, err := url.Parse(URL); err == nil {
+		URLPath = parsedURL.Path
+	} else if index := strings.IndexAny(URLPath, "?#"); index >= 0 {
+		URLPath = URLPath[:index]
+	}
+
+	extension := strings.ToLower(strings.TrimPrefix(path.Ext(URLPath), "."))
+
+	if extension == "" {
 		return
 	}
 
-	if !includeSubdomains && parsedURL.Domain != parsedDomain.Domain && parsedURL.Domain != "www."+parsedDomain.Domain {
+	for index := range extensions {
+		if strings.ToLower(strings.TrimPrefix(extensions[index], ".")) == extension {
+			matches = true
+
+			return
+		}
+	}
+
+	return
+}
+
+// DefaultAllowedSchemes are the schemes of URLs emitted when the
+// configuration doesn't specify them.
+var DefaultAllowedSchemes = []string{"http", "https"}
+
+// IsAllowedURL reports whether URL has a host and one of schemes, compared
+// case-insensitively, or of DefaultAllowedSchemes if schemes is nil.
+func IsAllowedURL(URL string, schemes []string) (allowed bool) {
+	parsedURL, err := url.Parse(URL)
+	if err != nil || parsedURL.Host == "" {
 		return
 	}
 
-	isInScope = true
+	if schemes == nil {
+		schemes = DefaultAllowedSchemes
+	}
+
+	for _, scheme := range schemes {
+		if strings.EqualFold(strings.TrimSpace(scheme), parsedURL.Scheme) {
+			allowed = true
+
+			return
+		}
+	}
+
+	return
+}
+
+// schemelessURLRegex matches URLs without scheme starting with a domain name,
+// e.g. `example.com/path` or `example.com:8080`.
+var schemelessURLRegex = regexp.MustCompile(`^[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}(:\d+)?([/?#]|$)`)
+
+// AddMissingScheme gives protocol-relative URLs, e.g. `//cdn.example.com/x`,
+// and URLs without scheme, e.g. `example.com/path`, a scheme: https if their
+// port is 443, http otherwise. Other URLs are returned unchanged.
+func AddMissingScheme(URL string) (fixedURL string) {
+	fixedURL = URL
+
+	switch {
+	case strings.HasPrefix(URL, "//"):
+		fixedURL = inferScheme(URL[2:]) + ":" + URL
+	case strings.Contains(URL, "://"), strings.HasPrefix(URL, "/"):
+	case schemelessURLRegex.MatchString(URL):
+		fixedURL = inferScheme(URL) + "://" + URL
+	}
+
+	return
+}
+
+// inferScheme returns the scheme of a URL without scheme, from its port.
+func inferScheme(URL string) (scheme string) {
+	scheme = "http"
+
+	host, _, _ := strings.Cut(URL, "/")
+	host, _, _ = strings.Cut(host, "?")
+	host, _, _ = strings.Cut(host, "#")
+
+	if strings.HasSuffix(host, ":443") {
+		scheme = "https"
+	}
 
 	return
 }
@@ -164,3 +455,90 @@ func findUnbalancedBracket(s string, openChar, closeChar rune) int {
 
 	return -1 // All brackets are balanced
 }
+
+// NormalizeURL returns a canonical form of URL for deduplication: the scheme
+// and host are lowercased, the host punycode encoded, default ports,
+// fragments and empty paths dropped and, if sortQuery is set, query
+// parameters sorted. URLs that do not parse, or have no host, are returned
+// unchanged.
+func NormalizeURL(URL string, sortQuery bool) (normalized string) {
+	normalized = URL
+
+	parsedURL, err := url.Parse(URL)
+	if err != nil || parsedURL.Host == "" {
+		return
+	}
+
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+
+	hostname, port := strings.ToLower(parsedURL.Hostname()), parsedURL.Port()
+
+	// as getHostname, internationalized hosts in their punycode form.
+	if ASCII, err := idna.ToASCII(hostname); err == nil {
+		hostname = ASCII
+	}
+
+	if (parsedURL.Scheme == "http" && port == "80") || (parsedURL.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		parsedURL.Host = net.JoinHostPort(hostname, port)
+	case strings.Contains(hostname, ":"):
+		parsedURL.Host = "[" + hostname + "]"
+	default:
+		parsedURL.Host = hostname
+	}
+
+	if parsedURL.Path == "" {
+		parsedURL.Path = "/"
+	}
+
+	parsedURL.Fragment = ""
+	parsedURL.RawFragment = ""
+
+	if sortQuery && parsedURL.RawQuery != "" {
+		// sort the raw pairs, re-encoding them through url.Values would
+		// alter their escaping.
+		pairs := strings.Split(parsedURL.RawQuery, "&")
+
+		sort.Strings(pairs)
+
+		parsedURL.RawQuery = strings.Join(pairs, "&")
+	}
+
+	normalized = parsedURL.String()
+
+	return
+}
+
+// CollapsedParamValue replaces query parameter values collapsed by
+// CollapseParamValues.
+const CollapsedParamValue = "FUZZ"
+
+// CollapseParamValues replaces the value of every query parameter of URL with
+// CollapsedParamValue, so that URLs differing only in parameter values
+// collapse into one. URLs that do not parse are returned unchanged.
+func CollapseParamValues(URL string) (collapsed string) {
+	collapsed = URL
+
+	parsedURL, err := url.Parse(URL)
+	if err != nil || parsedURL.RawQuery == "" {
+		return
+	}
+
+	pairs := strings.Split(parsedURL.RawQuery, "&")
+
+	for index := range pairs {
+		key, _, _ := strings.Cut(pairs[index], "=")
+
+		pairs[index] = key + "=" + CollapsedParamValue
+	}
+
+	parsedURL.RawQuery = strings.Join(pairs, "&")
+
+	collapsed = parsedURL.String()
+
+	return
+}
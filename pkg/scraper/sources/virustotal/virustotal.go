@@ -0,0 +1,136 @@
+package virustotal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hueristiq/hqgolimit"
+	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+type getURLsResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		Attributes struct {
+			URL string `json:"url"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Meta struct {
+		Cursor string `json:"cursor"`
+	} `json:"meta"`
+	Links struct {
+		Self string `json:"self"`
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+type Source struct{}
+
+func init() {
+	sources.RegisterSource("virustotal", func() sources.Source {
+		return &Source{}
+	})
+}
+
+// the public API allows 4 requests per minute.
+var limiter = hqgolimit.New(&hqgolimit.Options{
+	RequestsPerMinute: 4,
+})
+
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
+	results := make(chan sources.Result)
+
+	go func() {
+		defer close(results)
+
+		var err error
+
+		var key string
+
+		key, err = sources.PickKey(source.Name(), config.Keys.VirusTotal)
+		if err != nil {
+			return
+		}
+
+		getURLsReqHeaders := map[string]string{
+			"x-apikey": key,
+		}
+
+		getURLsReqURL := fmt.Sprintf("https://www.virustotal.com/api/v3/domains/%s/urls?limit=40", domain)
+
+		for getURLsReqURL != "" {
+			if ctx.Err() != nil {
+				return
+			}
+
+			limiter.Wait()
+
+			config.Jitter(ctx)
+
+			var getURLsRes *http.Response
+
+			getURLsRes, err = httpclient.Get(ctx, getURLsReqURL, "", getURLsReqHeaders)
+			if err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				httpclient.DiscardResponse(getURLsRes)
+
+				return
+			}
+
+			var getURLsResData getURLsResponse
+
+			if err = json.NewDecoder(getURLsRes.Body).Decode(&getURLsResData); err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+
+				results <- result
+
+				getURLsRes.Body.Close()
+
+				return
+			}
+
+			getURLsRes.Body.Close()
+
+			for _, item := range getURLsResData.Data {
+				URL := item.Attributes.URL
+
+				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, source.Name(), URL)
+
+					continue
+				}
+
+				result := sources.Result{
+					Type:   sources.URL,
+					Source: source.Name(),
+					Value:  URL,
+				}
+
+				results <- result
+			}
+
+			getURLsReqURL = getURLsResData.Links.Next
+		}
+	}()
+
+	return results
+}
+
+func (source *Source) Name() string {
+	return "virustotal"
+}
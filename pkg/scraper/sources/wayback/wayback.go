@@ -1,82 +1,104 @@
 package wayback
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/hueristiq/hqgohttp/headers"
+	"github.com/hueristiq/hqgohttp/status"
 	"github.com/hueristiq/hqgolimit"
 	"github.com/hueristiq/xurlfind3r/pkg/httpclient"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
 	"github.com/spf13/cast"
 )
 
-type Source struct{}
+type Source struct {
+	// Client makes the source's requests, httpclient.DefaultClient if nil.
+	Client httpclient.Client
 
-var limiter = hqgolimit.New(&hqgolimit.Options{
-	RequestsPerMinute: 40,
-})
+	limiter     *hqgolimit.RateLimiter
+	limiterOnce sync.Once
 
-func (source *Source) Run(config *sources.Configuration, domain string) <-chan sources.Result {
-	results := make(chan sources.Result)
-
-	go func() {
-		defer close(results)
+	cache     *httpclient.Cache
+	cacheOnce sync.Once
 
-		var err error
+	// sem bounds, to the configured concurrency, the snapshot listings and
+	// contents requested at once by robots, sitemap and source parsing.
+	sem     chan struct{}
+	semOnce sync.Once
+}
 
-		getPagesReqURL := formatURL(domain, config.IncludeSubdomains) + "&showNumPages=true"
+func init() {
+	sources.RegisterSource("wayback", func() sources.Source {
+		return &Source{}
+	})
+}
 
-		limiter.Wait()
+const (
+	// DefaultConcurrency is the number of snapshots fetched and parsed at once
+	// when the configuration doesn't specify one.
+	DefaultConcurrency = 10
+	// DefaultRateLimit is the number of requests per minute made to
+	// archive.org when the configuration doesn't specify one.
+	DefaultRateLimit = 40
+	// DefaultBaseURL is the CDX and replay server used when the configuration
+	// doesn't specify one.
+	DefaultBaseURL = "https://web.archive.org"
+)
 
-		var getPagesRes *http.Response
+func (source *Source) Run(ctx context.Context, config *sources.Configuration, domain string) <-chan sources.Result {
+	results := make(chan sources.Result)
 
-		getPagesRes, err = httpclient.SimpleGet(getPagesReqURL)
-		if err != nil {
-			result := sources.Result{
-				Type:   sources.Error,
-				Source: source.Name(),
-				Error:  err,
-			}
+	source.init(config)
 
-			results <- result
+	go func() {
+		defer close(results)
 
-			httpclient.DiscardResponse(getPagesRes)
+		var err error
 
-			return
-		}
+		if config.StateFile != "" {
+			config, err = withHighWaterMark(config, domain)
+			if err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
 
-		var pages uint
+				results <- result
 
-		if err = json.NewDecoder(getPagesRes.Body).Decode(&pages); err != nil {
-			result := sources.Result{
-				Type:   sources.Error,
-				Source: source.Name(),
-				Error:  err,
+				return
 			}
+		}
 
-			results <- result
-
-			getPagesRes.Body.Close()
+		waybackURLs := [][]string{}
 
-			return
-		}
+		// whether every page was listed, for the high-water mark to be saved.
+		complete := true
 
-		getPagesRes.Body.Close()
+		for _, listingURL := range formatURLs(domain, config) {
+			getPagesReqURL := listingURL + "&showNumPages=true"
 
-		waybackURLs := [][]string{}
+			source.limiter.Wait()
 
-		for page := uint(0); page < pages; page++ {
-			getURLsReqURL := fmt.Sprintf("%s&page=%d", formatURL(domain, config.IncludeSubdomains), page)
+			config.Jitter(ctx)
 
-			limiter.Wait()
+			var getPagesRes *http.Response
 
-			var getURLsRes *http.Response
+			config.Log().Debug("wayback: requesting %s", getPagesReqURL)
 
-			getURLsRes, err = httpclient.SimpleGet(getURLsReqURL)
+			getPagesRes, err = source.client().Get(ctx, getPagesReqURL)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -86,14 +108,14 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 				results <- result
 
-				httpclient.DiscardResponse(getURLsRes)
+				httpclient.DiscardResponse(getPagesRes)
 
 				return
 			}
 
-			var getURLsResData [][]string
+			var pages uint
 
-			if err = json.NewDecoder(getURLsRes.Body).Decode(&getURLsResData); err != nil {
+			if err = json.NewDecoder(getPagesRes.Body).Decode(&pages); err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
 					Source: source.Name(),
@@ -102,142 +124,902 @@ func (source *Source) Run(config *sources.Configuration, domain string) <-chan s
 
 				results <- result
 
-				getURLsRes.Body.Close()
+				getPagesRes.Body.Close()
 
 				return
 			}
 
-			getURLsRes.Body.Close()
+			getPagesRes.Body.Close()
+
+			for page := uint(0); page < pages; page++ {
+				if ctx.Err() != nil {
+					return
+				}
+
+				getURLsReqURL := fmt.Sprintf("%s&page=%d", listingURL, page)
+
+				var getURLsResData [][]string
+
+				getURLsResData, err = source.getURLsPage(ctx, config, getURLsReqURL)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+
+					result := sources.Result{
+						Type:   sources.Error,
+						Source: source.Name(),
+						Error:  fmt.Errorf("skipping wayback page %d: %w", page, err),
+					}
+
+					results <- result
+
+					complete = false
 
-			// check if there's results, wayback's pagination response
-			// is not always correct when using a filter
-			if len(getURLsResData) == 0 {
-				break
+					continue
+				}
+
+				// check if there's results, wayback's pagination response
+				// is not always correct when using a filter
+				if len(getURLsResData) == 0 {
+					break
+				}
+
+				for _, row := range getURLsResData {
+					if isHeaderRow(row) {
+						continue
+					}
+
+					waybackURLs = append(waybackURLs, row)
+				}
 			}
+		}
 
-			// output results
-			// Slicing as [1:] to skip first result by default
-			waybackURLs = append(waybackURLs, getURLsResData[1:]...)
+		if config.WaybackLatestOnly && !config.WaybackSkipMetadata {
+			waybackURLs = latestCaptures(waybackURLs)
 		}
 
-		mediaURLRegex := regexp.MustCompile(`(?i)\.(apng|bpm|png|bmp|gif|heif|ico|cur|jpg|jpeg|jfif|pjp|pjpeg|psd|raw|svg|tif|tiff|webp|xbm|3gp|aac|flac|mpg|mpeg|mp3|mp4|m4a|m4v|m4p|oga|ogg|ogv|mov|wav|webm|eot|woff|woff2|ttf|otf|pdf)(?:\?|#|$)`)
 		robotsURLsRegex := regexp.MustCompile(`^(https?)://[^ "]+/robots.txt$`)
 
-		for _, waybackURL := range waybackURLs {
-			URL := waybackURL[1]
+		highWaterMark := ""
 
-			if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+		defer func() {
+			if config.StateFile == "" || !complete || highWaterMark == "" || ctx.Err() != nil {
+				return
+			}
+
+			if err := sources.SaveHighWaterMark(config.StateFile, domain, highWaterMark); err != nil {
+				results <- sources.Result{
+					Type:   sources.Error,
+					Source: source.Name(),
+					Error:  err,
+				}
+			}
+		}()
+
+		for _, waybackURL := range waybackURLs {
+			if ctx.Err() != nil {
 				return
 			}
 
-			result := sources.Result{
-				Type:   sources.URL,
-				Source: source.Name(),
-				Value:  URL,
+			result, ok := source.parseRow(config, waybackURL)
+			if !ok {
+				continue
+			}
+
+			if result.Timestamp > highWaterMark {
+				highWaterMark = result.Timestamp
+			}
+
+			URL := result.Value
+
+			if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+				config.OutOfScope(results, source.Name(), URL)
+
+				config.Log().Debug("wayback: %s out of scope", URL)
+
+				continue
 			}
 
 			results <- result
 
-			if mediaURLRegex.MatchString(URL) {
-				return
+			if config.CountOnly || sources.MatchExtension(URL, skipSourceExtensions(config)) {
+				continue
 			}
 
 			if config.ParseWaybackRobots && robotsURLsRegex.MatchString(URL) {
-				parseWaybackRobots(config, URL, results)
+				source.parseWaybackRobots(ctx, config, domain, URL, results)
 
-				return
+				continue
 			}
 
 			if config.ParseWaybackSource {
-				parseWaybackSource(config, domain, URL, results)
+				if !isParsedMIMEType(config, result.MIMEType) {
+					config.Log().Debug("wayback: not parsing %s of type %s", URL, result.MIMEType)
+
+					continue
+				}
+
+				source.parseWaybackSource(ctx, config, domain, URL, result.MIMEType, results)
 			}
 		}
 	}()
 
-	return results
+	return deduplicate(config, results)
+}
+
+// withHighWaterMark returns a copy of config listing, for domain, only the
+// captures more recent than the timestamp saved in its state file, if later
+// than WaybackFrom.
+func withHighWaterMark(config *sources.Configuration, domain string) (updated *sources.Configuration, err error) {
+	updated = config
+
+	var highWaterMark string
+
+	highWaterMark, err = sources.LoadHighWaterMark(config.StateFile, domain)
+	if err != nil || highWaterMark == "" {
+		return
+	}
+
+	timestamp, err := strconv.ParseUint(highWaterMark, 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid timestamp %q saved for %s in %s: %w", highWaterMark, domain, config.StateFile, err)
+
+		return
+	}
+
+	from := strconv.FormatUint(timestamp+1, 10)
+
+	// timestamps compare as strings once padded to their full 14 digits.
+	if current, ok := parseTimestamp(config.WaybackFrom); ok && current+strings.Repeat("0", 14-len(current)) >= from {
+		return
+	}
+
+	copied := *config
+	copied.WaybackFrom = from
+
+	updated = &copied
+
+	return
+}
+
+const (
+	// pageAttempts is the number of times a page of the CDX URLs listing is
+	// requested before it's skipped.
+	pageAttempts = 4
+	// pageRetryWait is the wait before the first retry of a page, doubled
+	// after each.
+	pageRetryWait = 2 * time.Second
+)
+
+// getURLsPage requests and decodes a page of the CDX URLs listing, retrying,
+// with exponential backoff, pages whose request or read fails, e.g. on
+// connections dropped mid-stream.
+func (source *Source) getURLsPage(ctx context.Context, config *sources.Configuration, URL string) (rows [][]string, err error) {
+	wait := pageRetryWait
+
+	for attempt := 1; attempt <= pageAttempts; attempt++ {
+		if attempt > 1 {
+			config.Log().Debug("wayback: retrying %s in %s: %s", URL, wait, err)
+
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+
+				return
+			case <-time.After(wait):
+			}
+
+			wait *= 2
+		}
+
+		source.limiter.Wait()
+
+		config.Jitter(ctx)
+
+		config.Log().Debug("wayback: requesting %s", URL)
+
+		var res *http.Response
+
+		res, err = source.client().Get(ctx, URL)
+		if err != nil {
+			httpclient.DiscardResponse(res)
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			continue
+		}
+
+		rows = nil
+
+		err = json.NewDecoder(res.Body).Decode(&rows)
+
+		res.Body.Close()
+
+		if err == nil {
+			return
+		}
+	}
+
+	return
+}
+
+// latestCaptures returns, of rows of the CDX URLs listing, as requested by
+// formatURL with WaybackLatestOnly, the most recent row of each urlkey, in the
+// order urlkeys are first listed. Rows without urlkey are kept as is.
+func latestCaptures(rows [][]string) (latest [][]string) {
+	latest = make([][]string, 0, len(rows))
+
+	indexes := make(map[string]int)
+
+	for _, row := range rows {
+		if len(row) < 6 {
+			latest = append(latest, row)
+
+			continue
+		}
+
+		index, ok := indexes[row[5]]
+		if !ok {
+			indexes[row[5]] = len(latest)
+
+			latest = append(latest, row)
+
+			continue
+		}
+
+		// timestamps, of 14 digits, compare as strings.
+		if row[0] > latest[index][0] {
+			latest[index] = row
+		}
+	}
+
+	return
+}
+
+// deduplicate forwards results, dropping URLs already forwarded, and logs
+// errors. URLs are compared in their sources.NormalizeURL form.
+func deduplicate(config *sources.Configuration, results <-chan sources.Result) <-chan sources.Result {
+	deduplicated := make(chan sources.Result)
+
+	go func() {
+		defer close(deduplicated)
+
+		seen := sources.NewSeen(config)
+
+		for result := range results {
+			if result.Type == sources.Error {
+				config.Log().Error("%s: %s", result.Source, result.Error)
+			}
+
+			if result.Type == sources.URL {
+				if seen.Seen(sources.NormalizeURL(result.Value, config.SortQueryParams)) {
+					continue
+				}
+			}
+
+			deduplicated <- result
+		}
+	}()
+
+	return deduplicated
+}
+
+// DefaultSkipSourceExtensions are the extensions of URLs not worth parsing
+// for robots or source, images, audio, video, fonts and PDFs, when the
+// configuration doesn't specify any.
+var DefaultSkipSourceExtensions = []string{
+	"apng", "bpm", "png", "bmp", "gif", "heif", "ico", "cur", "jpg", "jpeg", "jfif", "pjp", "pjpeg", "psd", "raw", "svg", "tif", "tiff", "webp", "xbm",
+	"3gp", "aac", "flac", "mpg", "mpeg", "mp3", "mp4", "m4a", "m4v", "m4p", "oga", "ogg", "ogv", "mov", "wav", "webm",
+	"eot", "woff", "woff2", "ttf", "otf",
+	"pdf",
+}
+
+// parseRow parses a row of the CDX URLs listing, as requested by formatURL,
+// into a result.
+func (source *Source) parseRow(config *sources.Configuration, row []string) (result sources.Result, ok bool) {
+	result = sources.Result{
+		Type:   sources.URL,
+		Source: source.Name(),
+	}
+
+	if config.WaybackSkipMetadata {
+		if len(row) < 1 {
+			return
+		}
+
+		result.Value = row[0]
+	} else {
+		if len(row) < 4 {
+			return
+		}
+
+		result.Timestamp = row[0]
+		result.Value = row[1]
+		result.MIMEType = row[2]
+		result.StatusCode = cast.ToInt(row[3])
+		result.ReplayURL = fmt.Sprintf("%s/web/%s/%s", baseURL(config), result.Timestamp, result.Value)
+	}
+
+	ok = result.Value != ""
+
+	return
+}
+
+// DefaultParseMIMETypes are the archived MIME types of URLs whose snapshots
+// are parsed for source when the configuration doesn't specify them.
+var DefaultParseMIMETypes = []string{
+	"text/html", "application/xhtml+xml",
+	"text/css",
+	"application/javascript", "text/javascript", "application/x-javascript",
+	"application/json",
+	"text/xml", "application/xml",
+}
+
+// isParsedMIMEType reports whether snapshots of the given archived MIME type
+// are parsed for source. Unknown types, including CDX's `unk` and revisit
+// records', are.
+func isParsedMIMEType(config *sources.Configuration, MIMEType string) bool {
+	MIMEType = strings.ToLower(strings.TrimSpace(MIMEType))
+
+	if MIMEType == "" || MIMEType == "unk" || MIMEType == "warc/revisit" {
+		return true
+	}
+
+	MIMEType, _, _ = strings.Cut(MIMEType, ";")
+
+	patterns := config.ParseMIMETypes
+
+	if patterns == nil {
+		patterns = DefaultParseMIMETypes
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+		if matched, err := path.Match(pattern, MIMEType); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// client is replayClient, caching the CDX and availability responses, if
+// configured.
+func (source *Source) client() (client httpclient.Client) {
+	client = source.replayClient()
+
+	if source.cache != nil {
+		client = source.cache.Client(client)
+	}
+
+	return
+}
+
+// replayClient makes the source's requests, uncached, as snapshots are
+// streamed, and too many, and large, to be held.
+func (source *Source) replayClient() (client httpclient.Client) {
+	client = httpclient.DefaultClient
+
+	if source.Client != nil {
+		client = source.Client
+	}
+
+	return
+}
+
+// init sets the source's rate limiter and cache up, on first use.
+func (source *Source) init(config *sources.Configuration) {
+	source.limiterOnce.Do(func() {
+		requestsPerMinute := DefaultRateLimit
+
+		if config.WaybackRateLimit > 0 {
+			requestsPerMinute = config.WaybackRateLimit
+		}
+
+		source.limiter = hqgolimit.New(&hqgolimit.Options{
+			RequestsPerMinute: requestsPerMinute,
+		})
+	})
+
+	source.cacheOnce.Do(func() {
+		if config.Cache || config.CacheDir != "" {
+			source.cache = httpclient.NewCache(config.CacheDir, config.CacheTTL)
+			source.cache.Refresh = config.RefreshCache
+		}
+	})
+
+	source.semOnce.Do(func() {
+		source.sem = make(chan struct{}, concurrency(config))
+	})
+}
+
+// acquire waits for a slot of the source's semaphore, false if ctx is done
+// first. Slots are given back with release.
+func (source *Source) acquire(ctx context.Context, config *sources.Configuration) bool {
+	source.init(config)
+
+	select {
+	case source.sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (source *Source) release() {
+	<-source.sem
+}
+
+func baseURL(config *sources.Configuration) string {
+	if config.WaybackBaseURL != "" {
+		return strings.TrimSuffix(config.WaybackBaseURL, "/")
+	}
+
+	return DefaultBaseURL
+}
+
+func skipSourceExtensions(config *sources.Configuration) []string {
+	if config.SkipSourceExtensions != nil {
+		return config.SkipSourceExtensions
+	}
+
+	return DefaultSkipSourceExtensions
+}
+
+func concurrency(config *sources.Configuration) int {
+	if config.Concurrency > 0 {
+		return config.Concurrency
+	}
+
+	return DefaultConcurrency
+}
+
+// formatURLs returns the CDX URLs listings of domain. Without subdomains, and
+// a configured matchType, the domain and its www host, both in scope, are
+// matched as hosts, for captures of other hosts, later dropped out of scope,
+// not to be listed.
+func formatURLs(domain string, config *sources.Configuration) (URLs []string) {
+	switch {
+	case config.WaybackMatchType != "":
+		return []string{formatURL(domain+"&matchType="+config.WaybackMatchType, config)}
+	case config.IncludeSubdomains:
+		return []string{formatURL("*."+domain+"/*", config)}
+	}
+
+	return []string{
+		formatURL(domain+"&matchType=host", config),
+		formatURL("www."+domain+"&matchType=host", config),
+	}
+}
+
+// formatURL returns the CDX URLs listing of query, the `url` parameter, and
+// its `matchType`, if any.
+func formatURL(query string, config *sources.Configuration) (URL string) {
+	fields := "timestamp,original,mimetype,statuscode,digest"
+	collapse := "urlkey"
+
+	switch {
+	case config.WaybackSkipMetadata:
+		fields = "original"
+	case config.WaybackLatestOnly:
+		// rows are told apart by urlkey for latestCaptures.
+		fields += ",urlkey"
+		collapse = "none"
+	}
+
+	URL = fmt.Sprintf("%s/cdx/search/cdx?url=%s&output=json&fl=%s", baseURL(config), query, fields)
+	URL += formatCollapse(config.WaybackCollapse, collapse)
+	URL += formatTimestampRange(config)
+	URL += formatStatusCodeFilters(config)
+
+	return
+}
+
+// formatStatusCodeFilters returns the CDX `filter` query parameters for the
+// configured status codes. Included status codes are combined into a single
+// filter, as CDX requires every filter to match, while each excluded status
+// code gets a negated filter of its own.
+func formatStatusCodeFilters(config *sources.Configuration) (parameters string) {
+	included := []string{}
+
+	for _, statusCode := range config.WaybackStatusCodes {
+		if statusCode < 0 {
+			parameters += fmt.Sprintf("&filter=!statuscode:%d", -statusCode)
+
+			continue
+		}
+
+		included = append(included, strconv.Itoa(statusCode))
+	}
+
+	if len(included) > 0 {
+		parameters += fmt.Sprintf("&filter=statuscode:(%s)", strings.Join(included, "|"))
+	}
+
+	return
+}
+
+// formatCollapse returns the CDX `collapse` query parameter for collapse, or
+// fallback if not set, if any.
+func formatCollapse(collapse, fallback string) (parameter string) {
+	if collapse == "" {
+		collapse = fallback
+	}
+
+	if collapse != "none" {
+		parameter = "&collapse=" + collapse
+	}
+
+	return
 }
 
-func formatURL(domain string, includeSubdomains bool) (URL string) {
-	if includeSubdomains {
-		domain = "*." + domain
+// formatTimestampRange returns the CDX `from` and `to` query parameters for
+// the configured timestamp range. Malformed timestamps are ignored.
+func formatTimestampRange(config *sources.Configuration) (parameters string) {
+	if from, ok := parseTimestamp(config.WaybackFrom); ok {
+		parameters += "&from=" + from
+	}
+
+	if to, ok := parseTimestamp(config.WaybackTo); ok {
+		parameters += "&to=" + to
+	}
+
+	return
+}
+
+// parseTimestamp validates a CDX timestamp, i.e. 4 (YYYY) up to 14
+// (YYYYMMDDhhmmss) digits. Longer values are clamped to 14 digits.
+func parseTimestamp(value string) (timestamp string, ok bool) {
+	timestamp = strings.TrimSpace(value)
+
+	if len(timestamp) < 4 {
+		return
+	}
+
+	for _, r := range timestamp {
+		if r < '0' || r > '9' {
+			return
+		}
+	}
+
+	if len(timestamp) > 14 {
+		timestamp = timestamp[:14]
 	}
 
-	URL = fmt.Sprintf("http://web.archive.org/cdx/search/cdx?url=%s/*&output=json&collapse=urlkey&fl=timestamp,original,mimetype,statuscode,digest", domain)
+	ok = true
 
 	return
 }
 
-func getSnapshots(URL string) (snapshots [][2]string, err error) {
-	getSnapshotsReqURL := fmt.Sprintf("https://web.archive.org/cdx/search/cdx?url=%s&output=json&fl=timestamp,original&collapse=digest", URL)
+// Snapshot is a capture of a URL by the wayback machine.
+type Snapshot struct {
+	Timestamp string
+	Original  string
+}
+
+// Snapshots lists the captures of URL, within the configured time range,
+// collapsed as configured, by default one per distinct content.
+//
+// archive.org sometimes answers, under load, with a blank body: the listing is
+// then requested again, with exponential backoff, before failing with
+// ErrEmptyCDXResponse. An empty JSON array, or just the header row, is an
+// empty listing.
+func (source *Source) Snapshots(ctx context.Context, config *sources.Configuration, URL string) (snapshots []Snapshot, err error) {
+	source.init(config)
+
+	wait := pageRetryWait
+
+	for attempt := 1; ; attempt++ {
+		snapshots, err = source.getSnapshots(ctx, config, URL)
+		if !errors.Is(err, ErrEmptyCDXResponse) || attempt == pageAttempts {
+			return
+		}
+
+		config.Log().Debug("wayback: retrying snapshots of %s in %s: %s", URL, wait, err)
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+
+			return
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+	}
+}
+
+func (source *Source) getSnapshots(ctx context.Context, config *sources.Configuration, URL string) (snapshots []Snapshot, err error) {
+	getSnapshotsReqURL := fmt.Sprintf("%s/cdx/search/cdx?url=%s&output=json&fl=timestamp,original", baseURL(config), URL)
+	getSnapshotsReqURL += formatCollapse(config.WaybackSnapshotsCollapse, "digest")
+	getSnapshotsReqURL += formatTimestampRange(config)
 
 	var getSnapshotsRes *http.Response
 
-	limiter.Wait()
+	source.limiter.Wait()
+
+	config.Jitter(ctx)
+
+	config.Log().Debug("wayback: requesting %s", getSnapshotsReqURL)
 
-	getSnapshotsRes, err = httpclient.SimpleGet(getSnapshotsReqURL)
+	getSnapshotsRes, err = source.client().Get(ctx, getSnapshotsReqURL)
 	if err != nil {
+		httpclient.DiscardResponse(getSnapshotsRes)
+
 		return
 	}
 
-	if cast.ToInt(getSnapshotsRes.Header.Get(headers.ContentLength)) == 0 {
+	var body []byte
+
+	body, err = io.ReadAll(getSnapshotsRes.Body)
+
+	getSnapshotsRes.Body.Close()
+
+	if err != nil {
 		return
 	}
 
-	if err = json.NewDecoder(getSnapshotsRes.Body).Decode(&snapshots); err != nil {
-		getSnapshotsRes.Body.Close()
+	snapshots, err = parseSnapshots(body)
+
+	return
+}
+
+var (
+	// ErrMalformedCDXResponse is returned, wrapped, when a CDX response isn't
+	// the expected JSON array of rows, e.g. an HTML error page.
+	ErrMalformedCDXResponse = errors.New("malformed CDX response")
+	// ErrEmptyCDXResponse is returned when a CDX response's body is blank,
+	// rather than an empty JSON array.
+	ErrEmptyCDXResponse = errors.New("empty CDX response")
+)
+
+// cdxFields are the names of the CDX fields, as listed in header rows.
+var cdxFields = map[string]bool{
+	"urlkey":     true,
+	"timestamp":  true,
+	"original":   true,
+	"mimetype":   true,
+	"statuscode": true,
+	"digest":     true,
+	"length":     true,
+}
+
+// isHeaderRow reports whether row is a CDX listing's header row, of field
+// names, rather than a capture, whichever fields are listed. Captures' fields
+// never all are field names: their original is a URL.
+func isHeaderRow(row []string) bool {
+	if len(row) == 0 {
+		return false
+	}
+
+	for _, field := range row {
+		if !cdxFields[field] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseSnapshots parses a CDX snapshots listing, a JSON array of
+// `[timestamp, original]` rows headed by the fields' names. A blank body, a
+// transient failure, is no listing at all: ErrEmptyCDXResponse.
+func parseSnapshots(body []byte) (snapshots []Snapshot, err error) {
+	body = bytes.TrimSpace(body)
+
+	if len(body) == 0 {
+		err = ErrEmptyCDXResponse
 
 		return
 	}
 
-	getSnapshotsRes.Body.Close()
+	if body[0] != '[' {
+		err = fmt.Errorf("%w: %q", ErrMalformedCDXResponse, truncate(string(body), 100))
+
+		return
+	}
+
+	var rows [][]string
+
+	if err = json.Unmarshal(body, &rows); err != nil {
+		err = fmt.Errorf("%w: %w", ErrMalformedCDXResponse, err)
 
-	if len(snapshots) < 2 {
 		return
 	}
 
-	snapshots = snapshots[1:]
+	for index, row := range rows {
+		if len(row) < 2 {
+			err = fmt.Errorf("%w: row %d has %d fields, expected 2", ErrMalformedCDXResponse, index, len(row))
+
+			return
+		}
+
+		if isHeaderRow(row) {
+			continue
+		}
+
+		snapshots = append(snapshots, Snapshot{Timestamp: row[0], Original: row[1]})
+	}
 
 	return
 }
 
-func getSnapshotContent(snapshot [2]string) (content string, err error) {
-	var (
-		timestamp = snapshot[0]
-		URL       = snapshot[1]
-	)
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+
+	return value[:length] + "..."
+}
+
+// replayModifiers returns the replay modes tried, in order, to fetch a
+// snapshot: the configured one first, iframe (`if_`) by default, then the
+// other of iframe and identity (`id_`), which serves the original bytes,
+// and last the unmodified replay.
+func replayModifiers(config *sources.Configuration) []string {
+	if config.WaybackReplayModifier == "id_" {
+		return []string{"id_", "if_", ""}
+	}
+
+	return []string{"if_", "id_", ""}
+}
+
+// ErrSnapshotNotFound is returned when none of the replay modes could serve
+// a snapshot.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// isSnapshotNotFoundStatus reports whether a replay's status means the
+// snapshot can't be served: gone or missing captures, or an unreachable
+// origin (523). Redirects, e.g. to the nearest capture, are followed.
+func isSnapshotNotFoundStatus(code int) bool {
+	return code == status.NotFound || code == status.Gone || code == 523
+}
+
+// snapshotNotFoundFingerprint is found in the page served for snapshots that
+// can't be replayed.
+const snapshotNotFoundFingerprint = "This page can't be displayed. Please use the correct URL address to access"
+
+// Content fetches a snapshot's content, falling back through replayModifiers
+// until one of them serves it.
+func (source *Source) Content(ctx context.Context, config *sources.Configuration, snapshot Snapshot) (content string, err error) {
+	source.init(config)
+
+	for _, modifier := range replayModifiers(config) {
+		if ctx.Err() != nil {
+			err = ctx.Err()
 
-	getSnapshotContentReqURL := fmt.Sprintf("https://web.archive.org/web/%sif_/%s", timestamp, URL)
+			return
+		}
+
+		var reader io.ReadCloser
 
-	limiter.Wait()
+		reader, err = source.getSnapshotReplay(ctx, config, snapshot, modifier)
+		if err == nil {
+			var body []byte
+
+			body, err = io.ReadAll(reader)
+
+			reader.Close()
+
+			content = string(body)
+		}
+
+		if err == nil || errors.Is(err, httpclient.ErrBodyTooLarge) {
+			return
+		}
+
+		content = ""
+
+		config.Log().Debug("wayback: %s replay of %s failed: %s", modifier, snapshot.Original, err)
+	}
+
+	return
+}
+
+// ContentReader is Content, streamed: the reader, to be closed, reads the
+// content of the first of replayModifiers serving the snapshot.
+func (source *Source) ContentReader(ctx context.Context, config *sources.Configuration, snapshot Snapshot) (reader io.ReadCloser, err error) {
+	source.init(config)
+
+	for _, modifier := range replayModifiers(config) {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+
+			return
+		}
+
+		reader, err = source.getSnapshotReplay(ctx, config, snapshot, modifier)
+		if err == nil || errors.Is(err, httpclient.ErrBodyTooLarge) {
+			return
+		}
+
+		config.Log().Debug("wayback: %s replay of %s failed: %s", modifier, snapshot.Original, err)
+	}
+
+	return
+}
+
+// snapshotReplay is a replay's body, read through the buffer its beginning
+// was peeked into.
+type snapshotReplay struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// replayPeekSize is how much of a replay is peeked into, for the page served
+// for snapshots that can't be replayed.
+const replayPeekSize = 64 * 1024
+
+func (source *Source) getSnapshotReplay(ctx context.Context, config *sources.Configuration, snapshot Snapshot, modifier string) (reader io.ReadCloser, err error) {
+	getSnapshotContentReqURL := fmt.Sprintf("%s/web/%s%s/%s", baseURL(config), snapshot.Timestamp, modifier, snapshot.Original)
+
+	source.limiter.Wait()
+
+	config.Jitter(ctx)
 
 	var getSnapshotContentRes *http.Response
 
-	getSnapshotContentRes, err = httpclient.SimpleGet(getSnapshotContentReqURL)
+	config.Log().Debug("wayback: fetching snapshot %s", getSnapshotContentReqURL)
+
+	getSnapshotContentRes, err = source.replayClient().Get(ctx, getSnapshotContentReqURL)
 	if err != nil {
+		if getSnapshotContentRes != nil && isSnapshotNotFoundStatus(getSnapshotContentRes.StatusCode) {
+			err = fmt.Errorf("%w: %w", ErrSnapshotNotFound, err)
+		}
+
+		httpclient.DiscardResponse(getSnapshotContentRes)
+
 		return
 	}
 
-	content = cast.ToString(getSnapshotContentRes.Body)
+	// replays carry the capture's metadata headers, error pages don't.
+	isReplay := getSnapshotContentRes.Header.Get("Memento-Datetime") != "" || getSnapshotContentRes.Header.Get("X-Archive-Src") != ""
 
-	if content == "" {
-		getSnapshotContentRes.Body.Close()
+	buffered := bufio.NewReaderSize(getSnapshotContentRes.Body, replayPeekSize)
 
-		return
-	}
+	// as a last resort, for servers without the replay headers.
+	if !isReplay {
+		head, peekErr := buffered.Peek(replayPeekSize)
+		if peekErr != nil && !errors.Is(peekErr, io.EOF) && !errors.Is(peekErr, bufio.ErrBufferFull) {
+			getSnapshotContentRes.Body.Close()
 
-	getSnapshotContentRes.Body.Close()
+			err = peekErr
 
-	snapshotNotFoundFingerprint := "This page can't be displayed. Please use the correct URL address to access"
+			return
+		}
 
-	if strings.Contains(content, snapshotNotFoundFingerprint) {
-		err = fmt.Errorf("%s", snapshotNotFoundFingerprint)
+		if bytes.Contains(head, []byte(snapshotNotFoundFingerprint)) {
+			getSnapshotContentRes.Body.Close()
 
-		return
+			err = ErrSnapshotNotFound
+
+			return
+		}
+	}
+
+	reader = snapshotReplay{
+		Reader: buffered,
+		Closer: getSnapshotContentRes.Body,
 	}
 
 	return
 }
 
+// RunWildcard lists the captures of suffix's subdomains with the CDX domain
+// matchType.
+func (source *Source) RunWildcard(ctx context.Context, config *sources.Configuration, suffix string) <-chan sources.Result {
+	copied := *config
+
+	copied.IncludeSubdomains = true
+	copied.WaybackMatchType = "domain"
+
+	return source.Run(ctx, &copied, suffix)
+}
+
 func (source *Source) Name() string {
 	return "wayback"
 }
@@ -0,0 +1,1000 @@
+package wayback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// testCapture is a capture served by a testArchive.
+type testCapture struct {
+	timestamp string
+	original  string
+	mimetype  string
+	content   string
+}
+
+// testArchive is a CDX, availability and replay server over captures. Host
+// matches list the host's captures only, domain matches and wildcard
+// prefixes, e.g. `*.example.com/*`, those of its subdomains too, and URLs
+// without matchType those of the URL only. Plain prefixes, e.g.
+// `example.com/*`, list those of subdomains too, as reported of archive.org.
+type testArchive struct {
+	captures []testCapture
+
+	// rows is the number of CDX rows served, header rows excluded.
+	rows atomic.Int64
+	// cdxRequests, availabilityRequests and replayRequests are the number
+	// of CDX, availability and replay requests served.
+	cdxRequests          atomic.Int64
+	availabilityRequests atomic.Int64
+	replayRequests       atomic.Int64
+}
+
+func newTestArchive(t *testing.T, captures []testCapture) (archive *testArchive, server *httptest.Server) {
+	t.Helper()
+
+	archive = &testArchive{captures: captures}
+
+	server = httptest.NewServer(archive)
+
+	t.Cleanup(server.Close)
+
+	return
+}
+
+func (archive *testArchive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/cdx/search/cdx":
+		archive.cdxRequests.Add(1)
+
+		archive.serveCDX(w, r)
+	case r.URL.Path == AvailabilityAPIPath:
+		archive.availabilityRequests.Add(1)
+
+		archive.serveAvailability(w, r)
+	case strings.HasPrefix(r.URL.Path, "/web/"):
+		archive.replayRequests.Add(1)
+
+		archive.serveReplay(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (archive *testArchive) serveCDX(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("showNumPages") == "true" {
+		fmt.Fprint(w, "1")
+
+		return
+	}
+
+	if query.Get("page") != "" && query.Get("page") != "0" {
+		fmt.Fprint(w, "[]")
+
+		return
+	}
+
+	fields := strings.Split(query.Get("fl"), ",")
+
+	rows := [][]string{fields}
+
+	collapsed := map[string]bool{}
+
+	for index, capture := range archive.captures {
+		if !archive.matches(query.Get("url"), query.Get("matchType"), capture.original) {
+			continue
+		}
+
+		// as archive.org, keep the first capture of each URL.
+		if query.Get("collapse") == "urlkey" {
+			if collapsed[strings.ToLower(capture.original)] {
+				continue
+			}
+
+			collapsed[strings.ToLower(capture.original)] = true
+		}
+
+		values := map[string]string{
+			"timestamp":  capture.timestamp,
+			"original":   capture.original,
+			"mimetype":   capture.mimetype,
+			"statuscode": "200",
+			"digest":     fmt.Sprintf("DIGEST%d", index),
+			"urlkey":     strings.ToLower(capture.original),
+		}
+
+		row := make([]string, len(fields))
+
+		for i, field := range fields {
+			row[i] = values[field]
+		}
+
+		rows = append(rows, row)
+	}
+
+	archive.rows.Add(int64(len(rows) - 1))
+
+	_ = json.NewEncoder(w).Encode(rows)
+}
+
+func (archive *testArchive) matches(query, matchType, original string) bool {
+	parsed, err := url.Parse(original)
+	if err != nil {
+		return false
+	}
+
+	host := parsed.Hostname()
+
+	switch {
+	case matchType == "host":
+		return host == query
+	case matchType == "domain":
+		return host == query || strings.HasSuffix(host, "."+query)
+	case strings.HasPrefix(query, "*.") && strings.HasSuffix(query, "/*"):
+		domain := strings.TrimSuffix(strings.TrimPrefix(query, "*."), "/*")
+
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	case strings.HasSuffix(query, "/*"):
+		domain := strings.TrimSuffix(query, "/*")
+
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	default:
+		return original == query
+	}
+}
+
+// serveAvailability serves the most recent capture of `url`.
+func (archive *testArchive) serveAvailability(w http.ResponseWriter, r *http.Request) {
+	var closest availabilityResponse
+
+	for _, capture := range archive.captures {
+		if capture.original == r.URL.Query().Get("url") && capture.timestamp > closest.ArchivedSnapshots.Closest.Timestamp {
+			closest.ArchivedSnapshots.Closest.Available = true
+			closest.ArchivedSnapshots.Closest.Timestamp = capture.timestamp
+			closest.ArchivedSnapshots.Closest.Status = "200"
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(closest)
+}
+
+// serveReplay serves `/web/<timestamp><modifier>/<original>` replays.
+func (archive *testArchive) serveReplay(w http.ResponseWriter, r *http.Request) {
+	timestamp, original, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/web/"), "/")
+	if !found || len(timestamp) < 14 {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if r.URL.RawQuery != "" {
+		original += "?" + r.URL.RawQuery
+	}
+
+	for _, capture := range archive.captures {
+		if capture.original == original && capture.timestamp == timestamp[:14] {
+			w.Header().Set("Memento-Datetime", "Wed, 01 Jan 2020 00:00:00 GMT")
+
+			fmt.Fprint(w, capture.content)
+
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
+
+// testClient makes requests with net/http and, as httpclient's, fails on
+// statuses other than 200.
+type testClient struct{}
+
+func (testClient) Get(ctx context.Context, URL string) (res *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, http.NoBody)
+	if err != nil {
+		return
+	}
+
+	res, err = http.DefaultClient.Do(req)
+	if err == nil && res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status code %d received from %s", res.StatusCode, URL)
+	}
+
+	return
+}
+
+// testConfiguration is a configuration of server, without rate limiting.
+func testConfiguration(server *httptest.Server) *sources.Configuration {
+	return &sources.Configuration{
+		WaybackBaseURL:   server.URL,
+		WaybackRateLimit: 60 * 1000 * 1000,
+		RateLimitJitter:  -1,
+	}
+}
+
+// collectURLs runs the source and returns the URLs it finds, sorted.
+func collectURLs(t *testing.T, source *Source, config *sources.Configuration, domain string) (URLs []string) {
+	t.Helper()
+
+	for result := range source.Run(context.Background(), config, domain) {
+		switch result.Type {
+		case sources.URL:
+			URLs = append(URLs, result.Value)
+		case sources.Error:
+			t.Errorf("Run() error = %v", result.Error)
+		}
+	}
+
+	sort.Strings(URLs)
+
+	return
+}
+
+var testDomainCaptures = []testCapture{
+	{"20200101000000", "https://example.com/", "text/html", ""},
+	{"20200101000000", "https://example.com/about", "text/html", ""},
+	{"20200101000000", "https://www.example.com/", "text/html", ""},
+	{"20200101000000", "https://www.example.com/contact", "text/html", ""},
+	{"20200101000000", "https://api.example.com/v1/users", "application/json", ""},
+	{"20200101000000", "https://api.example.com/v1/orders", "application/json", ""},
+	{"20200101000000", "https://api.example.com/v2/users", "application/json", ""},
+	{"20200101000000", "https://cdn.example.com/app.js", "application/javascript", ""},
+	{"20200101000000", "https://cdn.example.com/app.css", "text/css", ""},
+	{"20200101000000", "https://blog.example.com/post/1", "text/html", ""},
+}
+
+// TestRunHostListing compares, without subdomains, the rows transferred by
+// the host listings to those of the prefix listing previously requested.
+func TestRunHostListing(t *testing.T) {
+	t.Parallel()
+
+	archive, server := newTestArchive(t, testDomainCaptures)
+
+	config := testConfiguration(server)
+
+	source := &Source{Client: testClient{}}
+
+	source.init(config)
+
+	rows, err := source.getURLsPage(context.Background(), config, formatURL("example.com/*", config)+"&page=0")
+	if err != nil {
+		t.Fatalf("getURLsPage() error = %v", err)
+	}
+
+	var before []string
+
+	for _, row := range rows {
+		if isHeaderRow(row) {
+			continue
+		}
+
+		if sources.IsInScope(row[1], "example.com", false) {
+			before = append(before, row[1])
+		}
+	}
+
+	sort.Strings(before)
+
+	beforeRows := archive.rows.Swap(0)
+
+	after := collectURLs(t, source, config, "example.com")
+
+	afterRows := archive.rows.Load()
+
+	t.Logf("rows transferred: %d with the prefix listing, %d with the host listings", beforeRows, afterRows)
+
+	if afterRows >= beforeRows {
+		t.Errorf("host listings transferred %d rows, want fewer than the prefix listing's %d", afterRows, beforeRows)
+	}
+
+	if strings.Join(after, " ") != strings.Join(before, " ") {
+		t.Errorf("Run() = %v, want the prefix listing's in scope URLs %v", after, before)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	robots := testCapture{
+		"20200101000000", "https://example.com/robots.txt", "text/plain",
+		"User-agent: *\nDisallow: /admin/\nAllow: /public/*.html$\n",
+	}
+
+	page := testCapture{
+		"20200101000000", "https://example.com/page", "text/html",
+		"<html>\n<base href=\"/docs/\">\n<a href=\"https://example.com/linked\">\n" +
+			"<a href=\"./guides/intro.html\">\n<a href=\"https://other.com/out\">\n</html>",
+	}
+
+	tests := []struct {
+		name     string
+		captures []testCapture
+		config   func(config *sources.Configuration)
+		want     []string
+	}{
+		{
+			name:     "without subdomains",
+			captures: testDomainCaptures,
+			want: []string{
+				"https://example.com/",
+				"https://example.com/about",
+				"https://www.example.com/",
+				"https://www.example.com/contact",
+			},
+		},
+		{
+			name:     "with subdomains",
+			captures: testDomainCaptures,
+			config: func(config *sources.Configuration) {
+				config.IncludeSubdomains = true
+			},
+			want: []string{
+				"https://api.example.com/v1/orders",
+				"https://api.example.com/v1/users",
+				"https://api.example.com/v2/users",
+				"https://blog.example.com/post/1",
+				"https://cdn.example.com/app.css",
+				"https://cdn.example.com/app.js",
+				"https://example.com/",
+				"https://example.com/about",
+				"https://www.example.com/",
+				"https://www.example.com/contact",
+			},
+		},
+		{
+			name:     "robots",
+			captures: []testCapture{robots},
+			config: func(config *sources.Configuration) {
+				config.ParseWaybackRobots = true
+			},
+			want: []string{
+				"https://example.com/admin/",
+				"https://example.com/public/",
+				"https://example.com/public/.html",
+				"https://example.com/robots.txt",
+			},
+		},
+		{
+			name:     "robots not parsed",
+			captures: []testCapture{robots},
+			want: []string{
+				"https://example.com/robots.txt",
+			},
+		},
+		{
+			name:     "source",
+			captures: []testCapture{page},
+			config: func(config *sources.Configuration) {
+				config.ParseWaybackSource = true
+			},
+			want: []string{
+				"https://example.com/docs/",
+				"https://example.com/docs/guides/intro.html",
+				"https://example.com/linked",
+				"https://example.com/page",
+			},
+		},
+		{
+			name:     "source not parsed",
+			captures: []testCapture{page},
+			want: []string{
+				"https://example.com/page",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, server := newTestArchive(t, tt.captures)
+
+			config := testConfiguration(server)
+
+			if tt.config != nil {
+				tt.config(config)
+			}
+
+			got := collectURLs(t, &Source{Client: testClient{}}, config, "example.com")
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Run() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunRobotsScope checks that the URLs of a robots.txt are scope checked
+// themselves, not through the robots.txt's URL: those of another domain are
+// out of scope, if in scope of the robots.txt.
+func TestRunRobotsScope(t *testing.T) {
+	t.Parallel()
+
+	robots := testCapture{
+		"20200101000000", "https://example.com/robots.txt", "text/plain",
+		"User-agent: *\nDisallow: //evil.com/admin/\nAllow: /public/\n",
+	}
+
+	_, server := newTestArchive(t, []testCapture{robots})
+
+	config := testConfiguration(server)
+
+	config.ParseWaybackRobots = true
+	config.EmitOutOfScope = true
+
+	var inScope, outOfScope []string
+
+	for result := range (&Source{Client: testClient{}}).Run(context.Background(), config, "example.com") {
+		switch result.Type {
+		case sources.URL:
+			inScope = append(inScope, result.Value)
+		case sources.OutOfScope:
+			outOfScope = append(outOfScope, result.Value)
+		case sources.Error:
+			t.Errorf("Run() error = %v", result.Error)
+		}
+	}
+
+	sort.Strings(inScope)
+	sort.Strings(outOfScope)
+
+	if want := []string{"https://example.com/public/", "https://example.com/robots.txt"}; strings.Join(inScope, " ") != strings.Join(want, " ") {
+		t.Errorf("Run() = %v, want %v", inScope, want)
+	}
+
+	if want := []string{"https://evil.com/admin/"}; strings.Join(outOfScope, " ") != strings.Join(want, " ") {
+		t.Errorf("Run() out of scope = %v, want %v", outOfScope, want)
+	}
+}
+
+// TestRunConcurrency checks that Concurrency bounds the snapshot requests,
+// listings and contents, of robots and source parsing in flight at once,
+// across concurrent runs.
+func TestRunConcurrency(t *testing.T) {
+	t.Parallel()
+
+	domains := []string{"example.com", "example.org", "example.net"}
+
+	var captures []testCapture
+
+	for _, domain := range domains {
+		for _, timestamp := range []string{"20200101000000", "20200201000000", "20200301000000", "20200401000000"} {
+			captures = append(captures,
+				testCapture{timestamp, "https://" + domain + "/robots.txt", "text/plain", "User-agent: *\nDisallow: /private/\n"},
+				testCapture{timestamp, "https://" + domain + "/page", "text/html", `<a href="/linked">`},
+			)
+		}
+	}
+
+	archive := &testArchive{captures: captures}
+
+	var inFlight, maxInFlight atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// snapshot listings match URLs exactly, unlike the domain's listing.
+		if strings.HasPrefix(r.URL.Path, "/web/") || r.URL.Query().Get("matchType") == "" {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			for {
+				highest := maxInFlight.Load()
+				if current <= highest || maxInFlight.CompareAndSwap(highest, current) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		archive.ServeHTTP(w, r)
+	}))
+
+	t.Cleanup(server.Close)
+
+	config := testConfiguration(server)
+
+	config.ParseWaybackRobots = true
+	config.ParseWaybackSource = true
+	config.Concurrency = 2
+
+	source := &Source{Client: testClient{}}
+
+	wg := &sync.WaitGroup{}
+
+	for _, domain := range domains {
+		wg.Add(1)
+
+		go func(domain string) {
+			defer wg.Done()
+
+			if got := collectURLs(t, source, config, domain); len(got) != 4 {
+				t.Errorf("Run(%s) = %v, want 4 URLs", domain, got)
+			}
+		}(domain)
+	}
+
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > int64(config.Concurrency) {
+		t.Errorf("Run() made %d snapshot requests at once, want at most %d", got, config.Concurrency)
+	}
+}
+
+func TestRunWildcard(t *testing.T) {
+	t.Parallel()
+
+	captures := append([]testCapture{
+		{"20200101000000", "https://agency.gov/", "text/html", ""},
+		{"20200101000000", "https://www.state.gov/about", "text/html", ""},
+	}, testDomainCaptures...)
+
+	_, server := newTestArchive(t, captures)
+
+	source := &Source{Client: testClient{}}
+
+	var got []string
+
+	for result := range source.RunWildcard(context.Background(), testConfiguration(server), "gov") {
+		switch result.Type {
+		case sources.URL:
+			got = append(got, result.Value)
+		case sources.Error:
+			t.Errorf("RunWildcard() error = %v", result.Error)
+		}
+	}
+
+	sort.Strings(got)
+
+	want := []string{"https://agency.gov/", "https://www.state.gov/about"}
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("RunWildcard() = %v, want %v", got, want)
+	}
+}
+
+func TestIsHeaderRow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		row  []string
+		want bool
+	}{
+		{"header", []string{"original", "mimetype", "timestamp"}, true},
+		{"header, every field", []string{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}, true},
+		{"data row", []string{"https://example.com/", "text/html", "20200101000000"}, false},
+		{"data row, urlkey first", []string{"urlkey", "https://example.com/urlkey"}, false},
+		{"header, fewer fields", []string{"timestamp", "original"}, true},
+		{"empty row", []string{}, false},
+		{"nil row", nil, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := isHeaderRow(tt.row); got != tt.want {
+				t.Errorf("isHeaderRow(%q) = %v, want %v", tt.row, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSnapshots(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []Snapshot
+		wantErr error
+	}{
+		{
+			name: "header row",
+			body: `[["timestamp","original"],["20200101000000","https://example.com/"]]`,
+			want: []Snapshot{{Timestamp: "20200101000000", Original: "https://example.com/"}},
+		},
+		{
+			name: "without header row",
+			body: `[["20200101000000","https://example.com/"]]`,
+			want: []Snapshot{{Timestamp: "20200101000000", Original: "https://example.com/"}},
+		},
+		{name: "just the header row", body: `[["timestamp","original"]]`},
+		{name: "empty listing", body: `[]`},
+		{name: "blank", body: " \n", wantErr: ErrEmptyCDXResponse},
+		{name: "HTML", body: "<html>Error</html>", wantErr: ErrMalformedCDXResponse},
+		{name: "short row", body: `[["20200101000000"]]`, wantErr: ErrMalformedCDXResponse},
+		{name: "truncated", body: `[["timestamp","original"],["20200101000000","https://exa`, wantErr: ErrMalformedCDXResponse},
+		{name: "JSON object", body: `{"error":"Blocked Site Error"}`, wantErr: ErrMalformedCDXResponse},
+		{name: "rows of objects", body: `[{"timestamp":"20200101000000"}]`, wantErr: ErrMalformedCDXResponse},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseSnapshots([]byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseSnapshots() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parseSnapshots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSnapshotsErrorPayloads checks that CDX error payloads, whatever their
+// status, are descriptive errors rather than empty listings.
+func TestSnapshotsErrorPayloads(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantErr    error
+	}{
+		{"HTML error page", http.StatusOK, "<html><body>Wayback Machine is overloaded</body></html>", ErrMalformedCDXResponse},
+		{"truncated JSON array", http.StatusOK, `[["timestamp","original"],["2020`, ErrMalformedCDXResponse},
+		{"HTML error status", http.StatusForbidden, "<html><body>Forbidden</body></html>", nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+
+				fmt.Fprint(w, tt.body)
+			}))
+
+			t.Cleanup(server.Close)
+
+			snapshots, err := (&Source{Client: testClient{}}).Snapshots(context.Background(), testConfiguration(server), "https://example.com/")
+			if err == nil {
+				t.Fatalf("Snapshots() = %v, want an error", snapshots)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Snapshots() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if errors.Is(err, ErrEmptyCDXResponse) {
+				t.Errorf("Snapshots() error = %v, want it not retried as empty", err)
+			}
+		})
+	}
+}
+
+// TestSnapshotsEmptyResponses checks that blank listings, transient, are
+// requested again while empty ones aren't.
+func TestSnapshotsEmptyResponses(t *testing.T) {
+	t.Parallel()
+
+	listing := `[["timestamp","original"],["20200101000000","https://example.com/"]]`
+
+	tests := []struct {
+		name     string
+		bodies   []string
+		want     int
+		requests int64
+	}{
+		{"blank, then listed", []string{"", listing}, 1, 2},
+		{"whitespace, then listed", []string{" \n", listing}, 1, 2},
+		{"empty array", []string{"[]"}, 0, 1},
+		{"just the header row", []string{`[["timestamp","original"]]`}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int64
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				request := int(requests.Add(1))
+
+				if request > len(tt.bodies) {
+					request = len(tt.bodies)
+				}
+
+				fmt.Fprint(w, tt.bodies[request-1])
+			}))
+
+			t.Cleanup(server.Close)
+
+			snapshots, err := (&Source{Client: testClient{}}).Snapshots(context.Background(), testConfiguration(server), "https://example.com/")
+			if err != nil {
+				t.Fatalf("Snapshots() error = %v", err)
+			}
+
+			if len(snapshots) != tt.want {
+				t.Errorf("Snapshots() = %v, want %d snapshots", snapshots, tt.want)
+			}
+
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("Snapshots() made %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+// TestRunCache checks that, with Cache, CDX responses are served from the
+// cache on a second run while snapshots are fetched again.
+func TestRunCache(t *testing.T) {
+	t.Parallel()
+
+	page := testCapture{"20200101000000", "https://example.com/page", "text/html", `<a href="https://example.com/linked">`}
+
+	archive, server := newTestArchive(t, []testCapture{page})
+
+	config := testConfiguration(server)
+
+	config.ParseWaybackSource = true
+	config.Cache = true
+
+	source := &Source{Client: testClient{}}
+
+	first := collectURLs(t, source, config, "example.com")
+
+	cdxRequests, replayRequests := archive.cdxRequests.Load(), archive.replayRequests.Load()
+
+	second := collectURLs(t, source, config, "example.com")
+
+	if strings.Join(first, " ") != strings.Join(second, " ") {
+		t.Errorf("second Run() = %v, want %v", second, first)
+	}
+
+	if got := archive.cdxRequests.Load() - cdxRequests; got != 0 {
+		t.Errorf("second Run() made %d CDX requests, want 0", got)
+	}
+
+	if got := archive.replayRequests.Load() - replayRequests; got != replayRequests {
+		t.Errorf("second Run() made %d replay requests, want %d", got, replayRequests)
+	}
+}
+
+// TestRunAvailability checks that, with WaybackAvailability, only the
+// closest snapshot, got from the availability API under the base URL, is
+// parsed.
+func TestRunAvailability(t *testing.T) {
+	t.Parallel()
+
+	archive, server := newTestArchive(t, []testCapture{
+		{"20200101000000", "https://example.com/page", "text/html", `<a href="https://example.com/older">`},
+		{"20210101000000", "https://example.com/page", "text/html", `<a href="https://example.com/closest">`},
+	})
+
+	config := testConfiguration(server)
+
+	config.ParseWaybackSource = true
+	config.WaybackAvailability = true
+
+	got := collectURLs(t, &Source{Client: testClient{}}, config, "example.com")
+
+	want := []string{"https://example.com/closest", "https://example.com/page"}
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Run() = %v, want %v", got, want)
+	}
+
+	if got := archive.availabilityRequests.Load(); got != 1 {
+		t.Errorf("Run() made %d availability requests, want 1", got)
+	}
+
+	if got := archive.replayRequests.Load(); got != 1 {
+		t.Errorf("Run() made %d replay requests, want 1", got)
+	}
+}
+
+// TestContentRedirect checks that replays redirected, as archive.org does to
+// the nearest capture, are followed with the HTTP client, and that missing
+// ones are ErrSnapshotNotFound.
+func TestContentRedirect(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/web/20200101000000if_/https://example.com/page":
+			w.Header().Set("Location", "/web/20200102000000if_/https://example.com/page")
+			w.WriteHeader(http.StatusFound)
+		case "/web/20200102000000if_/https://example.com/page":
+			w.Header().Set("Memento-Datetime", "Thu, 02 Jan 2020 00:00:00 GMT")
+
+			fmt.Fprint(w, "nearest capture")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	config := testConfiguration(server)
+
+	source := &Source{}
+
+	content, err := source.Content(context.Background(), config, Snapshot{Timestamp: "20200101000000", Original: "https://example.com/page"})
+	if err != nil {
+		t.Fatalf("Content() error = %v", err)
+	}
+
+	if content != "nearest capture" {
+		t.Errorf("Content() = %q, want %q", content, "nearest capture")
+	}
+
+	_, err = source.Content(context.Background(), config, Snapshot{Timestamp: "20200101000000", Original: "https://example.com/missing"})
+	if !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Content() error = %v, want %v", err, ErrSnapshotNotFound)
+	}
+}
+
+// TestReplayModifiers checks that Content and ContentReader fall back through
+// the replay modes in order, the configured one first.
+func TestReplayModifiers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		modifier string
+		// served is the only mode the server serves the snapshot in.
+		served string
+		want   []string
+	}{
+		{"default", "", "if_", []string{"if_"}},
+		{"default, identity", "", "id_", []string{"if_", "id_"}},
+		{"default, unmodified", "", "", []string{"if_", "id_", ""}},
+		{"default, not found", "", "none", []string{"if_", "id_", ""}},
+		{"identity", "id_", "id_", []string{"id_"}},
+		{"identity, iframe", "id_", "if_", []string{"id_", "if_"}},
+		{"identity, not found", "id_", "none", []string{"id_", "if_", ""}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		for _, streamed := range []bool{false, true} {
+			streamed := streamed
+
+			name := tt.name
+
+			if streamed {
+				name += ", streamed"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				var mutex sync.Mutex
+
+				var requested []string
+
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					timestamp, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/web/"), "/")
+
+					modifier := strings.TrimPrefix(timestamp, "20200101000000")
+
+					mutex.Lock()
+					requested = append(requested, modifier)
+					mutex.Unlock()
+
+					if modifier != tt.served {
+						http.NotFound(w, r)
+
+						return
+					}
+
+					w.Header().Set("Memento-Datetime", "Wed, 01 Jan 2020 00:00:00 GMT")
+
+					fmt.Fprint(w, "served")
+				}))
+
+				t.Cleanup(server.Close)
+
+				config := testConfiguration(server)
+
+				config.WaybackReplayModifier = tt.modifier
+
+				source := &Source{Client: testClient{}}
+
+				snapshot := Snapshot{Timestamp: "20200101000000", Original: "https://example.com/page"}
+
+				var content string
+
+				var err error
+
+				if streamed {
+					var reader io.ReadCloser
+
+					reader, err = source.ContentReader(context.Background(), config, snapshot)
+					if err == nil {
+						body, _ := io.ReadAll(reader)
+
+						reader.Close()
+
+						content = string(body)
+					}
+				} else {
+					content, err = source.Content(context.Background(), config, snapshot)
+				}
+
+				if tt.served == "none" {
+					if !errors.Is(err, ErrSnapshotNotFound) {
+						t.Errorf("error = %v, want %v", err, ErrSnapshotNotFound)
+					}
+				} else if err != nil || content != "served" {
+					t.Errorf("content = %q, error = %v, want %q", content, err, "served")
+				}
+
+				if strings.Join(requested, ",") != strings.Join(tt.want, ",") {
+					t.Errorf("requested modes %q, want %q", requested, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// TestExtractRewrittenLinks checks that links of `if_` replays, rewritten to
+// archived captures, are recovered as their originals.
+func TestExtractRewrittenLinks(t *testing.T) {
+	t.Parallel()
+
+	base, _ := url.Parse("https://example.com/page")
+
+	content := strings.Join([]string{
+		`<a href="/web/20200101000000/https://example.com/relative">`,
+		`<a href="//web.archive.org/web/20200101000000/https://example.com/protocol-relative">`,
+		`<a href="https://web.archive.org/web/20200101000000/https://example.com/absolute">`,
+		`<script src="/web/20200101000000js_/https://example.com/script.js"></script>`,
+		`<img src="/web/20200101000000im_/http://example.com/image.png">`,
+	}, "\n")
+
+	got := map[string]bool{}
+
+	for _, URL := range extractLinks(base, ContentTypeHTML, []byte(content)) {
+		if strings.Contains(URL, "web.archive.org") || strings.Contains(URL, "/web/2020") {
+			t.Errorf("extractLinks() = %s, want it unwrapped", URL)
+		}
+
+		got[URL] = true
+	}
+
+	for _, want := range []string{
+		"https://example.com/relative",
+		"https://example.com/protocol-relative",
+		"https://example.com/absolute",
+		"https://example.com/script.js",
+		"http://example.com/image.png",
+	} {
+		if !got[want] {
+			t.Errorf("extractLinks() = %v, missing %s", got, want)
+		}
+	}
+}
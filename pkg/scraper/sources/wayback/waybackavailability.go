@@ -0,0 +1,71 @@
+package wayback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// AvailabilityAPIPath is the path, under the base URL, of the availability
+// API, which returns the snapshot of a URL closest to a timestamp.
+const AvailabilityAPIPath = "/wayback/available"
+
+type availabilityResponse struct {
+	ArchivedSnapshots struct {
+		Closest struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// getClosestSnapshot returns the snapshot of URL closest to the configured
+// `to` timestamp, or the most recent one, through the availability API. ok is
+// false if the API has no snapshot of URL.
+func (source *Source) getClosestSnapshot(ctx context.Context, config *sources.Configuration, URL string) (snapshot Snapshot, ok bool, err error) {
+	getAvailabilityReqURL := fmt.Sprintf("%s%s?url=%s", baseURL(config), AvailabilityAPIPath, url.QueryEscape(URL))
+
+	if timestamp, valid := parseTimestamp(config.WaybackTo); valid {
+		getAvailabilityReqURL += "&timestamp=" + timestamp
+	}
+
+	source.limiter.Wait()
+
+	config.Jitter(ctx)
+
+	config.Log().Debug("wayback: requesting %s", getAvailabilityReqURL)
+
+	var getAvailabilityRes *http.Response
+
+	getAvailabilityRes, err = source.client().Get(ctx, getAvailabilityReqURL)
+	if err != nil {
+		return
+	}
+
+	var getAvailabilityResData availabilityResponse
+
+	err = json.NewDecoder(getAvailabilityRes.Body).Decode(&getAvailabilityResData)
+
+	getAvailabilityRes.Body.Close()
+
+	if err != nil {
+		return
+	}
+
+	closest := getAvailabilityResData.ArchivedSnapshots.Closest
+
+	if !closest.Available || closest.Timestamp == "" {
+		return
+	}
+
+	snapshot = Snapshot{Timestamp: closest.Timestamp, Original: URL}
+	ok = true
+
+	return
+}
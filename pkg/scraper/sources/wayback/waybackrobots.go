@@ -1,19 +1,113 @@
 package wayback
 
 import (
-	"path/filepath"
+	"context"
+	"net/url"
 	"regexp"
 	"strings"
 	"sync"
 
-	"github.com/hueristiq/hqgourl"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
 )
 
-func parseWaybackRobots(_ *sources.Configuration, URL string, results chan sources.Result) {
-	robotsEntryRegex := regexp.MustCompile(`(Allow|Disallow):\s?.+`)
+// resolveReference resolves a, possibly relative, reference against a base URL.
+func resolveReference(base, reference string) (resolved string, err error) {
+	var baseURL, referenceURL *url.URL
+
+	baseURL, err = url.Parse(base)
+	if err != nil {
+		return
+	}
+
+	referenceURL, err = url.Parse(reference)
+	if err != nil {
+		return
+	}
+
+	resolved = baseURL.ResolveReference(referenceURL).String()
+
+	return
+}
+
+// parseRobotsPaths returns the paths of a robots.txt's Allow and Disallow
+// rules, across all user-agent groups. Comments and blank lines are skipped.
+// For wildcard rules, e.g. `/admin/*.php$`, both the literal prefix before the
+// first wildcard, `/admin/`, and the rule without its wildcards and end
+// anchor, `/admin/.php`, are returned.
+func parseRobotsPaths(content string) (paths []string) {
+	seen := map[string]struct{}{}
+
+	add := func(robotsPath string) {
+		if robotsPath == "" || robotsPath == "/" {
+			return
+		}
+
+		if !strings.HasPrefix(robotsPath, "/") {
+			robotsPath = "/" + robotsPath
+		}
+
+		if _, ok := seen[robotsPath]; ok {
+			return
+		}
+
+		seen[robotsPath] = struct{}{}
+
+		paths = append(paths, robotsPath)
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+
+		directive, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		if directive != "allow" && directive != "disallow" {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+
+		if !strings.ContainsAny(value, "*$") {
+			add(value)
+
+			continue
+		}
+
+		if index := strings.IndexAny(value, "*$"); index > 0 {
+			add(value[:index])
+		}
+
+		concrete := strings.TrimSuffix(value, "$")
+
+		for strings.Contains(concrete, "/*/") {
+			concrete = strings.ReplaceAll(concrete, "/*/", "/")
+		}
+
+		concrete = strings.ReplaceAll(concrete, "*", "")
+
+		add(concrete)
+	}
+
+	return
+}
+
+func (source *Source) parseWaybackRobots(ctx context.Context, config *sources.Configuration, domain, URL string, results chan sources.Result) {
+	sitemapEntryRegex := regexp.MustCompile(`(?im)^\s*Sitemap:\s*(\S+)`)
+
+	if !source.acquire(ctx, config) {
+		return
+	}
+
+	snapshots, err := source.Snapshots(ctx, config, URL)
+
+	source.release()
 
-	snapshots, err := getSnapshots(URL)
 	if err != nil {
 		result := sources.Result{
 			Type:   sources.Error,
@@ -27,14 +121,23 @@ func parseWaybackRobots(_ *sources.Configuration, URL string, results chan sourc
 	}
 
 	wg := &sync.WaitGroup{}
+	sitemaps := &sync.Map{}
+
+	for _, snapshot := range snapshots {
+		if !source.acquire(ctx, config) {
+			break
+		}
 
-	for _, row := range snapshots {
 		wg.Add(1)
 
-		go func(row [2]string) {
-			defer wg.Done()
+		go func(snapshot Snapshot) {
+			defer func() {
+				source.release()
+
+				wg.Done()
+			}()
 
-			content, err := getSnapshotContent(row)
+			content, err := source.Content(ctx, config, snapshot)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -47,42 +150,17 @@ func parseWaybackRobots(_ *sources.Configuration, URL string, results chan sourc
 				return
 			}
 
-			matches := robotsEntryRegex.FindAllStringSubmatch(content, -1)
-
-			if len(matches) < 1 {
-				return
-			}
-
-			for _, match := range matches {
-				entry := match[0]
-
-				temp := strings.Split(entry, ": ")
-
-				if len(temp) <= 1 {
-					continue
-				}
-
-				robotsURL := temp[1]
-
-				if robotsURL == "/" || robotsURL == "*" || robotsURL == "" {
+			for _, match := range sitemapEntryRegex.FindAllStringSubmatch(content, -1) {
+				sitemapURL, err := resolveReference(URL, match[1])
+				if err != nil {
 					continue
 				}
 
-				robotsURL = strings.ReplaceAll(robotsURL, "*", "")
-
-				for strings.HasPrefix(robotsURL, "/") {
-					if len(robotsURL) >= 1 {
-						robotsURL = robotsURL[1:] // Ex. /*/test or /*/*/demo
-					}
-				}
-
-				for strings.HasSuffix(robotsURL, "/") {
-					if len(robotsURL) >= 1 {
-						robotsURL = robotsURL[0 : len(robotsURL)-1]
-					}
-				}
+				sitemaps.Store(sitemapURL, struct{}{})
+			}
 
-				parsedURL, err := hqgourl.Parse(URL)
+			for _, robotsPath := range parseRobotsPaths(content) {
+				robotsURL, err := resolveReference(URL, robotsPath)
 				if err != nil {
 					result := sources.Result{
 						Type:   sources.Error,
@@ -95,7 +173,13 @@ func parseWaybackRobots(_ *sources.Configuration, URL string, results chan sourc
 					continue
 				}
 
-				robotsURL = parsedURL.Scheme + "://" + filepath.Join(parsedURL.Domain, robotsURL)
+				if !sources.IsInScope(robotsURL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, "wayback:robots", robotsURL)
+
+					config.Log().Debug("wayback: %s out of scope", robotsURL)
+
+					continue
+				}
 
 				result := sources.Result{
 					Type:   sources.URL,
@@ -105,8 +189,14 @@ func parseWaybackRobots(_ *sources.Configuration, URL string, results chan sourc
 
 				results <- result
 			}
-		}(row)
+		}(snapshot)
 	}
 
 	wg.Wait()
+
+	sitemaps.Range(func(sitemapURL, _ interface{}) bool {
+		source.parseWaybackSitemap(ctx, config, domain, sitemapURL.(string), 0, results)
+
+		return ctx.Err() == nil
+	})
 }
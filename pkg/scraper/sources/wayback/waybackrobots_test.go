@@ -0,0 +1,90 @@
+package wayback
+
+import (
+	"strings"
+	"testing"
+)
+
+// testRobots is a robots.txt of several user-agent groups, with comments,
+// blank lines and wildcard rules.
+const testRobots = `# robots.txt for https://example.com/
+User-agent: Googlebot
+Disallow: /search          # internal search
+Disallow: /*.php$
+Allow: /public/*/index.html
+
+User-agent: Bingbot
+User-agent: Slurp
+Disallow: /private/
+disallow: /tmp/*
+
+# everyone else
+User-agent: *
+Crawl-delay: 10
+Disallow: /admin/
+Disallow: /search
+Allow: /
+Disallow:
+Disallow: cgi-bin/
+Disallow: /*?sessionid=
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestParseRobotsPaths(t *testing.T) {
+	t.Parallel()
+
+	want := []string{
+		"/search",
+		"/.php",
+		"/public/",
+		"/public/index.html",
+		"/private/",
+		"/tmp/",
+		"/admin/",
+		"/cgi-bin/",
+		"/?sessionid=",
+	}
+
+	got := parseRobotsPaths(testRobots)
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("parseRobotsPaths() = %q, want %q", got, want)
+	}
+
+	// with Windows line endings.
+	if got := parseRobotsPaths(strings.ReplaceAll(testRobots, "\n", "\r\n")); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("parseRobotsPaths() with CRLF = %q, want %q", got, want)
+	}
+}
+
+func TestResolveRobotsPaths(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		robotsPath string
+		want       string
+	}{
+		{"/admin/", "https://example.com/admin/"},
+		{"/public/index.html", "https://example.com/public/index.html"},
+		{"/?sessionid=", "https://example.com/?sessionid="},
+		{"//other.com/x", "https://other.com/x"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.robotsPath, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := resolveReference("https://example.com/robots.txt", tt.robotsPath)
+			if err != nil {
+				t.Fatalf("resolveReference() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("resolveReference() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package wayback
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// maxSitemapDepth caps how deep nested sitemap indexes are followed.
+const maxSitemapDepth = 3
+
+// sitemap is either a sitemap's urlset or a sitemap index, both of which list
+// their entries' URLs in loc elements.
+type sitemap struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+func (source *Source) parseWaybackSitemap(ctx context.Context, config *sources.Configuration, domain, URL string, depth int, results chan sources.Result) {
+	if depth > maxSitemapDepth || ctx.Err() != nil {
+		return
+	}
+
+	if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+		config.OutOfScope(results, "wayback:sitemap", URL)
+
+		config.Log().Debug("wayback: %s out of scope", URL)
+
+		return
+	}
+
+	content, ok, err := source.latestContent(ctx, config, URL)
+	if err != nil {
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: "wayback:sitemap",
+			Error:  err,
+		}
+
+		results <- result
+
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	var reader io.Reader = strings.NewReader(content)
+
+	// gzipped sitemaps, i.e. `.xml.gz`, are archived as is.
+	if strings.HasPrefix(content, "\x1f\x8b") {
+		reader, err = gzip.NewReader(bytes.NewReader([]byte(content)))
+		if err != nil {
+			result := sources.Result{
+				Type:   sources.Error,
+				Source: "wayback:sitemap",
+				Error:  err,
+			}
+
+			results <- result
+
+			return
+		}
+	}
+
+	var parsedSitemap sitemap
+
+	if err = xml.NewDecoder(reader).Decode(&parsedSitemap); err != nil {
+		result := sources.Result{
+			Type:   sources.Error,
+			Source: "wayback:sitemap",
+			Error:  err,
+		}
+
+		results <- result
+
+		return
+	}
+
+	for _, entry := range parsedSitemap.URLs {
+		sitemapURL := strings.TrimSpace(entry.Loc)
+
+		if !sources.IsInScope(sitemapURL, domain, config.IncludeSubdomains) {
+			config.OutOfScope(results, "wayback:sitemap", sitemapURL)
+
+			config.Log().Debug("wayback: %s out of scope", sitemapURL)
+
+			continue
+		}
+
+		result := sources.Result{
+			Type:   sources.URL,
+			Source: "wayback:sitemap",
+			Value:  sitemapURL,
+		}
+
+		results <- result
+	}
+
+	for _, entry := range parsedSitemap.Sitemaps {
+		source.parseWaybackSitemap(ctx, config, domain, strings.TrimSpace(entry.Loc), depth+1, results)
+	}
+}
+
+// latestContent returns the content of the latest snapshot of URL, the most
+// complete, as snapshots are listed from the oldest, holding a slot of the
+// source's semaphore while requesting the archive. ok is false if URL has no
+// snapshots, or ctx is done first.
+func (source *Source) latestContent(ctx context.Context, config *sources.Configuration, URL string) (content string, ok bool, err error) {
+	if !source.acquire(ctx, config) {
+		return
+	}
+
+	defer source.release()
+
+	snapshots, err := source.Snapshots(ctx, config, URL)
+	if err != nil || len(snapshots) < 1 {
+		return
+	}
+
+	content, err = source.Content(ctx, config, snapshots[len(snapshots)-1])
+
+	return content, err == nil, err
+}
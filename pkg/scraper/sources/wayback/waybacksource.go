@@ -1,39 +1,49 @@
 package wayback
 
 import (
-	"fmt"
-	"mime"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
 	"regexp"
-	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/hueristiq/hqgourl"
 	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
 )
 
-func parseWaybackSource(config *sources.Configuration, domain, URL string, results chan sources.Result) {
+// parseWaybackSource extracts URLs from the snapshots of URL, archived as
+// MIMEType, with the extractor registered for their content type.
+func (source *Source) parseWaybackSource(ctx context.Context, config *sources.Configuration, domain, URL, MIMEType string, results chan sources.Result) {
 	var err error
 
-	var snapshots [][2]string
+	var snapshots []Snapshot
 
-	snapshots, err = getSnapshots(URL)
-	if err != nil {
-		result := sources.Result{
-			Type:   sources.Error,
-			Source: "wayback:source",
-			Error:  err,
+	if config.WaybackAvailability {
+		snapshot, ok, availabilityErr := source.getClosestSnapshot(ctx, config, URL)
+		if availabilityErr != nil {
+			config.Log().Debug("wayback: availability of %s: %s", URL, availabilityErr)
 		}
 
-		results <- result
-
-		return
+		if ok {
+			snapshots = []Snapshot{snapshot}
+		}
 	}
 
-	lxExtractor := hqgourl.Extractor.Relaxed()
+	// without the availability API, or a snapshot from it, list them all.
+	if snapshots == nil {
+		if !source.acquire(ctx, config) {
+			return
+		}
 
-	var mdExtractor *regexp.Regexp
+		snapshots, err = source.Snapshots(ctx, config, URL)
+
+		source.release()
+	}
 
-	mdExtractor, err = hqgourl.Extractor.ModerateMatchHost(`(\w[a-zA-Z0-9][a-zA-Z0-9-\\.]*\.)?` + regexp.QuoteMeta(domain))
 	if err != nil {
 		result := sources.Result{
 			Type:   sources.Error,
@@ -46,18 +56,25 @@ func parseWaybackSource(config *sources.Configuration, domain, URL string, resul
 		return
 	}
 
-	regex1 := regexp.MustCompile(`^(//web\.archive\.org/web|https://web\.archive\.org/web|/web)/\d{14}([a-z]{2}_)?/.*`)
-	regex2 := regexp.MustCompile(`^https?://.*`)
+	baseHrefRegex := regexp.MustCompile(`(?i)<base\s[^>]*href\s*=\s*["']?([^"'\s>]+)`)
 
 	wg := &sync.WaitGroup{}
 
-	for _, row := range snapshots {
+	for _, snapshot := range snapshots {
+		if !source.acquire(ctx, config) {
+			break
+		}
+
 		wg.Add(1)
 
-		go func(row [2]string) {
-			defer wg.Done()
+		go func(snapshot Snapshot) {
+			defer func() {
+				source.release()
+
+				wg.Done()
+			}()
 
-			content, err := getSnapshotContent(row)
+			reader, err := source.ContentReader(ctx, config, snapshot)
 			if err != nil {
 				result := sources.Result{
 					Type:   sources.Error,
@@ -70,118 +87,154 @@ func parseWaybackSource(config *sources.Configuration, domain, URL string, resul
 				return
 			}
 
-			lxURLs := lxExtractor.FindAllString(content, -1)
+			defer reader.Close()
 
-			for _, lxURL := range lxURLs {
-				lxURL = sources.FixURL(lxURL)
+			buffered := bufio.NewReaderSize(reader, contentPeekSize)
 
-				// `/web/20230128054726/https://example.com/`
-				// `//web.archive.org/web/20230128054726/https://example.com/`
-				// `https://web.archive.org/web/20230128054726/https://example.com/`
-				// `/web/20040111155853js_/http://example.com/2003/mm_menu.js`
-				if regex1.MatchString(lxURL) {
-					URLs := mdExtractor.FindAllString(lxURL, -1)
+			// the document's kind, and base, are told from its beginning.
+			peeked, _ := buffered.Peek(contentPeekSize)
 
-					for _, URL := range URLs {
-						// `https://web.archive.org/web/20001110042700/mailto:info@safaricom.co.ke`->safaricom.co.ke
-						if !strings.HasPrefix(URL, "http") {
-							continue
-						}
+			prefix := string(peeked)
 
-						if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
-							continue
-						}
+			contentType := snapshotContentType(snapshot.Original, MIMEType, prefix)
 
-						result := sources.Result{
-							Type:   sources.URL,
-							Source: "wayback:source",
-							Value:  URL,
-						}
+			extractor, ok := newExtractor(contentType)
+			if !ok {
+				return
+			}
 
-						results <- result
-					}
+			// URLs are tagged with the kind of document they're found in.
+			tag := contentTypeTag(contentType)
 
-					continue
+			// relative references are resolved against the document's
+			// `<base href>`, if any, otherwise against the snapshot's URL.
+			base, err := url.Parse(snapshot.Original)
+			if err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: "wayback:source",
+					Error:  err,
 				}
 
-				// `http://www.safaricom.co.ke/`
-				// `https://web.archive.org/web/*/http://www.safaricom.co.ke/*`
-				// `//html5shim.googlecode.com/svn/trunk/html5.js``
-				if regex2.MatchString(lxURL) || strings.HasPrefix(lxURL, `//`) {
-					URLs := mdExtractor.FindAllString(lxURL, -1)
-
-					for _, URL := range URLs {
-						if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
-							continue
-						}
-
-						result := sources.Result{
-							Type:   sources.URL,
-							Source: "wayback:source",
-							Value:  URL,
-						}
+				results <- result
 
-						results <- result
-					}
+				return
+			}
 
-					continue
+			if match := baseHrefRegex.FindStringSubmatch(prefix); match != nil {
+				if reference, err := url.Parse(match[1]); err == nil {
+					base = base.ResolveReference(reference)
 				}
+			}
 
-				// text/javascript
-				_, _, err := mime.ParseMediaType(lxURL)
-				if err == nil {
-					result := sources.Result{
-						Type:   sources.Error,
-						Source: "wayback:source",
-						Error:  err,
-					}
+			found := func(URL string) {
+				if !sources.IsInScope(URL, domain, config.IncludeSubdomains) {
+					config.OutOfScope(results, tag, URL)
 
-					results <- result
+					config.Log().Debug("wayback: %s out of scope", URL)
 
-					continue
+					return
 				}
 
-				// `//archive.org/includes/analytics.js?v=c535ca67``
-				// `archive.org/components/npm/lit/polyfill-support.js?v=c535ca67`
-				// `archive.org/components/npm/@webcomponents/webcomponentsjs/webcomponents-bundle.js?v=c535ca67`
-				// `archive.org/includes/build/js/ia-topnav.min.js?v=c535ca67`
-				// `archive.org/includes/build/js/archive.min.js?v=c535ca67`
-				// `archive.org/includes/build/css/archive.min.css?v=c535ca67`
-				if strings.Contains(lxURL, "archive.org") {
-					continue
+				result := sources.Result{
+					Type:   sources.URL,
+					Source: tag,
+					Value:  URL,
 				}
 
-				parsedSourceURL, err := hqgourl.Parse(URL)
-				if err != nil {
-					result := sources.Result{
-						Type:   sources.Error,
-						Source: "wayback:source",
-						Error:  err,
+				results <- result
+			}
+
+			if streamExtractor, ok := extractor.(StreamExtractor); ok {
+				err = streamExtractor.ExtractStream(base, contentType, buffered, found)
+			} else {
+				err = readChunks(buffered, func(chunk []byte) {
+					for _, URL := range extractor.Extract(base, contentType, chunk) {
+						found(URL)
 					}
+				})
+			}
+			if err != nil {
+				result := sources.Result{
+					Type:   sources.Error,
+					Source: "wayback:source",
+					Error:  err,
+				}
 
-					results <- result
+				results <- result
+			}
+		}(snapshot)
+	}
 
-					continue
-				}
+	wg.Wait()
+}
 
-				lxURL = strings.TrimLeft(lxURL, "/")
+const (
+	// contentChunkSize is how much of a snapshot's content is extracted from
+	// at a time.
+	contentChunkSize = 1024 * 1024
+	// contentChunkTail is how far back from a chunk's end it may be cut, for
+	// no URL to span two chunks.
+	contentChunkTail = 4 * 1024
+	// contentPeekSize is how much of a snapshot's content is peeked into, to
+	// tell its kind and base.
+	contentPeekSize = 64 * 1024
+)
 
-				lxURL = fmt.Sprintf("%s://%s/%s", parsedSourceURL.Scheme, parsedSourceURL.Domain, lxURL)
+// readChunks calls fn with reader's content, up to contentChunkSize at a time.
+// Chunks are cut between URLs, see chunkCut, what's after the cut starting
+// the next chunk. Chunks are only valid until fn returns.
+func readChunks(reader io.Reader, fn func(chunk []byte)) (err error) {
+	buffer := make([]byte, contentChunkSize)
 
-				if !sources.IsInScope(lxURL, domain, config.IncludeSubdomains) {
-					continue
-				}
+	carried := 0
 
-				result := sources.Result{
-					Type:   sources.URL,
-					Source: "wayback:source",
-					Value:  lxURL,
-				}
+	for {
+		var read int
 
-				results <- result
+		read, err = io.ReadFull(reader, buffer[carried:])
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			if carried+read > 0 {
+				fn(buffer[:carried+read])
 			}
-		}(row)
+
+			return nil
+		}
+
+		if err != nil {
+			return
+		}
+
+		cut := chunkCut(buffer)
+
+		fn(buffer[:cut])
+
+		carried = copy(buffer, buffer[cut:])
 	}
+}
 
-	wg.Wait()
+// chunkCut returns where a full chunk is cut: after its last line break in its
+// last contentChunkTail bytes, failing that after its last whitespace, or tag
+// delimiter, there, and failing that, in tokens larger than that, at its end.
+func chunkCut(chunk []byte) (cut int) {
+	offset := len(chunk) - contentChunkTail
+
+	tail := chunk[offset:]
+
+	if index := bytes.LastIndexByte(tail, '\n'); index >= 0 {
+		return offset + index + 1
+	}
+
+	index := bytes.LastIndexFunc(tail, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '<' || r == '>'
+	})
+	if index >= 0 {
+		// after the whole rune, e.g. U+00A0, U+3000, multibyte.
+		_, size := utf8.DecodeRune(tail[index:])
+
+		return offset + index + size
+	}
+
+	return len(chunk)
 }
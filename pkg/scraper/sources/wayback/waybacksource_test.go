@@ -0,0 +1,377 @@
+package wayback
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+func TestExtractLinksReferences(t *testing.T) {
+	t.Parallel()
+
+	base, _ := url.Parse("https://example.com/docs/guide/page.html?page=1")
+
+	tests := []struct {
+		name      string
+		attribute string
+		reference string
+		want      []string
+	}{
+		{"absolute", "href", "https://example.com/absolute", []string{"https://example.com/absolute"}},
+		{"root relative", "href", "/api/v1", []string{"https://example.com/api/v1"}},
+		{"current directory", "href", "./intro.html", []string{"https://example.com/docs/guide/intro.html"}},
+		{"current directory, nested", "href", "./guides/intro.html", []string{"https://example.com/docs/guide/guides/intro.html"}},
+		{"parent directory", "href", "../img.png", []string{"https://example.com/docs/img.png"}},
+		{"parent directories", "href", "../../img/logo.png", []string{"https://example.com/img/logo.png"}},
+		{"protocol relative", "href", "//cdn.example.com/app.js", []string{"https://cdn.example.com/app.js"}},
+		{"query only", "href", "?a=1", []string{"https://example.com/docs/guide/page.html?a=1"}},
+		{"relative", "href", "img/a.png", []string{"https://example.com/docs/guide/img/a.png"}},
+		{"relative, nested", "src", "api/v1/users", []string{"https://example.com/docs/guide/api/v1/users"}},
+		{"relative, plural directory", "src", "images/logo.png", []string{"https://example.com/docs/guide/images/logo.png"}},
+		{"media type", "type", "text/javascript", nil},
+		{"media type, with a suffix", "type", "image/svg+xml", nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			URLs := extractLinks(base, ContentTypeHTML, []byte(`<a `+tt.attribute+`="`+tt.reference+`">`))
+
+			if !reflect.DeepEqual(URLs, tt.want) {
+				t.Errorf("extractLinks(%q) = %v, want %v", tt.reference, URLs, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadChunks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		separator string
+	}{
+		{"lines", "\n"},
+		{"spaces", " "},
+		{"tags", "><"},
+		{"no-break spaces", "\u00a0"},
+		{"ideographic spaces", "\u3000"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var content strings.Builder
+
+			URLs := 0
+
+			for content.Len() < 2*contentChunkSize+contentChunkSize/2 {
+				fmt.Fprintf(&content, `"https://example.com/path/%d"%s`, URLs, tt.separator)
+
+				URLs++
+			}
+
+			base, _ := url.Parse("https://example.com/")
+
+			seen := map[string]int{}
+
+			err := readChunks(strings.NewReader(content.String()), func(chunk []byte) {
+				if !utf8.Valid(chunk) {
+					t.Errorf("readChunks() cut a rune, in a chunk of %d bytes", len(chunk))
+				}
+
+				for _, URL := range extractLinks(base, ContentTypeHTML, chunk) {
+					seen[URL]++
+				}
+			})
+			if err != nil {
+				t.Fatalf("readChunks() error = %v", err)
+			}
+
+			if len(seen) != URLs {
+				t.Errorf("readChunks() extracted %d URLs, want %d", len(seen), URLs)
+			}
+
+			for i := 0; i < URLs; i++ {
+				URL := fmt.Sprintf("https://example.com/path/%d", i)
+
+				if seen[URL] != 1 {
+					t.Errorf("readChunks() extracted %s %d times, want 1", URL, seen[URL])
+				}
+			}
+		})
+	}
+}
+
+func TestReadChunksLongToken(t *testing.T) {
+	t.Parallel()
+
+	content := bytes.Repeat([]byte("a"), 2*contentChunkSize+1)
+
+	read := 0
+
+	err := readChunks(bytes.NewReader(content), func(chunk []byte) {
+		read += len(chunk)
+	})
+	if err != nil {
+		t.Fatalf("readChunks() error = %v", err)
+	}
+
+	if read != len(content) {
+		t.Errorf("readChunks() read %d bytes, want %d", read, len(content))
+	}
+}
+
+func TestJSExtractorCap(t *testing.T) {
+	t.Parallel()
+
+	base, _ := url.Parse("https://example.com/")
+
+	tests := []struct {
+		name   string
+		format string
+	}{
+		{"endpoints", "fetch(\"/api/%d\");\n"},
+		{"absolute links", "fetch(\"http://example.com/page/%d\");\n"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chunk := func(from int) []byte {
+				var content strings.Builder
+
+				for i := from; i < from+maxJSEndpoints*3/4; i++ {
+					fmt.Fprintf(&content, tt.format, i)
+				}
+
+				return []byte(content.String())
+			}
+
+			extractor := &jsExtractor{}
+
+			seen := map[string]bool{}
+
+			for _, body := range [][]byte{chunk(0), chunk(maxJSEndpoints)} {
+				for _, URL := range extractor.Extract(base, ContentTypeJavaScript, body) {
+					if seen[URL] {
+						t.Errorf("Extract() = %s twice", URL)
+					}
+
+					seen[URL] = true
+				}
+			}
+
+			if len(seen) != maxJSEndpoints {
+				t.Errorf("Extract() extracted %d URLs across chunks, want %d", len(seen), maxJSEndpoints)
+			}
+		})
+	}
+}
+
+func TestJSONExtractorStream(t *testing.T) {
+	t.Parallel()
+
+	base, _ := url.Parse("https://example.com/")
+
+	// a document larger than a chunk, a URL at its very end.
+	large := `{"padding":"` + strings.Repeat("a", 2*contentChunkSize) + `","url":"https://example.com/last"}`
+
+	var capped strings.Builder
+
+	capped.WriteString("[")
+
+	for i := 0; i < 2*maxJSONURLs; i++ {
+		if i > 0 {
+			capped.WriteString(",")
+		}
+
+		fmt.Fprintf(&capped, `"https://example.com/%d"`, i)
+	}
+
+	capped.WriteString("]")
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		count   int
+	}{
+		{
+			name:    "values",
+			content: `{"a":"https://example.com/a","b":["//example.com/b",{"c":"not a URL"}],"d":1}`,
+			want:    []string{"https://example.com/a", "http://example.com/b"},
+			count:   2,
+		},
+		{
+			name:    "keys",
+			content: `{"https://example.com/key":"https://example.com/value"}`,
+			want:    []string{"https://example.com/value"},
+			count:   1,
+		},
+		{
+			name:    "larger than a chunk",
+			content: large,
+			want:    []string{"https://example.com/last"},
+			count:   1,
+		},
+		{
+			name:    "capped",
+			content: capped.String(),
+			want:    []string{"https://example.com/0"},
+			count:   maxJSONURLs,
+		},
+		{
+			name:    "JSONP",
+			content: `{"a":"https://example.com/a"} callback("https://example.com/b")`,
+			want:    []string{"https://example.com/a", "https://example.com/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			extractor, _ := newExtractor(ContentTypeJSON)
+
+			streamExtractor, ok := extractor.(StreamExtractor)
+			if !ok {
+				t.Fatal("JSON extractor isn't a StreamExtractor")
+			}
+
+			var URLs []string
+
+			err := streamExtractor.ExtractStream(base, ContentTypeJSON, strings.NewReader(tt.content), func(URL string) {
+				URLs = append(URLs, URL)
+			})
+			if err != nil {
+				t.Fatalf("ExtractStream() error = %v", err)
+			}
+
+			for _, want := range tt.want {
+				found := false
+
+				for _, URL := range URLs {
+					if URL == want {
+						found = true
+
+						break
+					}
+				}
+
+				if !found {
+					t.Errorf("ExtractStream() = %v, missing %s", URLs, want)
+				}
+			}
+
+			if tt.count > 0 && len(URLs) != tt.count {
+				t.Errorf("ExtractStream() extracted %d URLs, want %d", len(URLs), tt.count)
+			}
+		})
+	}
+}
+
+// TestParseWaybackSourceCancel checks that, canceled mid-run, with replays in
+// flight, parseWaybackSource returns, leaking none of its goroutines.
+func TestParseWaybackSourceCancel(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	var captures []testCapture
+
+	for i := 0; i < 3*DefaultConcurrency; i++ {
+		captures = append(captures, testCapture{fmt.Sprintf("2020010100%04d", i), "https://example.com/page", "text/html", `<a href="https://example.com/linked">`})
+	}
+
+	archive := &testArchive{captures: captures}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var replays atomic.Int64
+
+	// the first replays are served, the next ones hang until canceled.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/web/") {
+			archive.ServeHTTP(w, r)
+
+			return
+		}
+
+		replay := replays.Add(1)
+
+		if replay <= 3 {
+			archive.ServeHTTP(w, r)
+
+			return
+		}
+
+		if replay == DefaultConcurrency {
+			cancel()
+		}
+
+		<-r.Context().Done()
+	}))
+
+	results := make(chan sources.Result)
+
+	go func() {
+		defer close(results)
+
+		(&Source{Client: testClient{}}).parseWaybackSource(ctx, testConfiguration(server), "example.com", "https://example.com/page", "text/html", results)
+	}()
+
+	timeout := time.After(10 * time.Second)
+
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-results:
+			closed = !ok
+		case <-timeout:
+			t.Fatal("results weren't closed after the context was canceled")
+		}
+	}
+
+	if got := replays.Load(); got >= int64(len(captures)) {
+		t.Errorf("%d replays requested, want fewer than %d after the context was canceled", got, len(captures))
+	}
+
+	server.Close()
+
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+
+	// goroutines take a moment to exit once their connections are closed.
+	deadline := time.Now().Add(5 * time.Second)
+
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := runtime.NumGoroutine(); got > baseline {
+		buffer := make([]byte, 1<<20)
+
+		t.Errorf("%d goroutines running, want %d:\n%s", got, baseline, buffer[:runtime.Stack(buffer, true)])
+	}
+}
@@ -0,0 +1,70 @@
+package wayback
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+var (
+	// `url("a.png")`, `url('a.png')` and `url(a.png)`
+	cssURLRegex = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)`)
+	// `@import "a.css"` and `@import 'a.css'`, `@import url(a.css)` is
+	// matched by cssURLRegex.
+	cssImportRegex = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// isCSS reports whether the snapshot is a stylesheet, by its URL's extension
+// or, failing that, its content starting with a CSS at-rule.
+func isCSS(URL, content string) bool {
+	if sources.MatchExtension(URL, []string{"css"}) {
+		return true
+	}
+
+	content = strings.ToLower(strings.TrimSpace(content))
+
+	return strings.HasPrefix(content, "@charset") || strings.HasPrefix(content, "@import")
+}
+
+// extractCSSReferences extracts the `url()` and `@import` references of a
+// stylesheet, skipping data URIs and fragment-only references.
+func extractCSSReferences(content string) (references []string) {
+	matches := cssURLRegex.FindAllStringSubmatch(content, -1)
+	matches = append(matches, cssImportRegex.FindAllStringSubmatch(content, -1)...)
+
+	for _, match := range matches {
+		reference := ""
+
+		for _, group := range match[1:] {
+			if group != "" {
+				reference = strings.TrimSpace(group)
+
+				break
+			}
+		}
+
+		if reference == "" || strings.HasPrefix(reference, "#") || strings.HasPrefix(strings.ToLower(reference), "data:") {
+			continue
+		}
+
+		references = append(references, reference)
+	}
+
+	return
+}
+
+// extractCSS is the CSS extractor: the stylesheet's references and, as for
+// HTML, the links found anywhere in it.
+func extractCSS(base *url.URL, contentType string, body []byte) (URLs []string) {
+	for _, reference := range extractCSSReferences(string(body)) {
+		if URL, ok := resolve(base, reference); ok {
+			URLs = append(URLs, URL)
+		}
+	}
+
+	URLs = append(URLs, extractLinks(base, contentType, body)...)
+
+	return
+}
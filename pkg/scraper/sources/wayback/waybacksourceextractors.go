@@ -0,0 +1,264 @@
+package wayback
+
+import (
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/hueristiq/hqgourl"
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// Extractor extracts the URLs referenced by a snapshot's content. An
+// extractor is created per snapshot, and given its content in chunks, in
+// order, so it may keep state, e.g. caps, across them.
+type Extractor interface {
+	// Extract returns the URLs referenced by body, a snapshot's content of
+	// contentType, or a chunk of it, relative ones resolved against base.
+	// Chunks are cut between URLs. body is only valid until Extract returns.
+	Extract(base *url.URL, contentType string, body []byte) []string
+}
+
+// StreamExtractor is an Extractor given a snapshot's whole content as a
+// stream instead, for documents that must be decoded whole, e.g. JSON.
+type StreamExtractor interface {
+	Extractor
+
+	// ExtractStream calls found with each URL referenced by reader, a
+	// snapshot's content of contentType, relative ones resolved against base.
+	ExtractStream(base *url.URL, contentType string, reader io.Reader, found func(URL string)) error
+}
+
+// ExtractorFunc adapts a function to an Extractor.
+type ExtractorFunc func(base *url.URL, contentType string, body []byte) []string
+
+func (fn ExtractorFunc) Extract(base *url.URL, contentType string, body []byte) []string {
+	return fn(base, contentType, body)
+}
+
+// Content types of snapshots the built-in extractors are registered for.
+const (
+	ContentTypeHTML       = "text/html"
+	ContentTypeJavaScript = "application/javascript"
+	ContentTypeCSS        = "text/css"
+	ContentTypeJSON       = "application/json"
+)
+
+var (
+	extractors   = map[string]func() Extractor{}
+	extractorsMu sync.RWMutex
+)
+
+func init() {
+	RegisterExtractor(ContentTypeHTML, func() Extractor {
+		return ExtractorFunc(extractLinks)
+	})
+	RegisterExtractor(ContentTypeJavaScript, func() Extractor {
+		return &jsExtractor{}
+	})
+	RegisterExtractor(ContentTypeCSS, func() Extractor {
+		return ExtractorFunc(extractCSS)
+	})
+	RegisterExtractor(ContentTypeJSON, func() Extractor {
+		return &jsonExtractor{}
+	})
+}
+
+// RegisterExtractor makes the extractors created by constructor, one per
+// snapshot, parse snapshots of contentType, a MIME type without parameters,
+// e.g. `application/xml`. Registering a content type twice replaces the
+// previous constructor, letting custom extractors override the built-in ones.
+func RegisterExtractor(contentType string, constructor func() Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	extractors[contentType] = constructor
+}
+
+func hasExtractor(contentType string) (ok bool) {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	_, ok = extractors[contentType]
+
+	return
+}
+
+func newExtractor(contentType string) (extractor Extractor, ok bool) {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	constructor, ok := extractors[contentType]
+	if !ok {
+		return
+	}
+
+	return constructor(), true
+}
+
+// snapshotContentType returns the content type of a snapshot of URL, archived
+// as MIMEType, from its content's prefix. JavaScript, CSS and JSON are told
+// by their URL's extension or content, as archived MIME types are often
+// wrong. Failing that, the archived MIME type is used if an extractor is
+// registered for it, and HTML otherwise.
+func snapshotContentType(URL, MIMEType, prefix string) (contentType string) {
+	switch {
+	case isJavaScript(URL):
+		return ContentTypeJavaScript
+	case isCSS(URL, prefix):
+		return ContentTypeCSS
+	case isJSON(URL, prefix):
+		return ContentTypeJSON
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(MIMEType); err == nil {
+		if hasExtractor(mediaType) {
+			return mediaType
+		}
+	}
+
+	return ContentTypeHTML
+}
+
+// contentTypeTags are the sources URLs are tagged with, by the content type
+// of the snapshot they're found in. Other content types are tagged
+// `wayback:source`.
+var contentTypeTags = map[string]string{
+	ContentTypeHTML:       "wayback:source:html",
+	ContentTypeJavaScript: "wayback:source:js",
+	ContentTypeCSS:        "wayback:source:css",
+	ContentTypeJSON:       "wayback:source:json",
+}
+
+func contentTypeTag(contentType string) string {
+	if tag, ok := contentTypeTags[contentType]; ok {
+		return tag
+	}
+
+	return "wayback:source"
+}
+
+var (
+	lxExtractor = hqgourl.Extractor.Relaxed()
+	mdExtractor = hqgourl.Extractor.Moderate()
+
+	waybackURLRegex  = regexp.MustCompile(`^(?://web\.archive\.org/web|https://web\.archive\.org/web|/web)/\d{14}(?:[a-z]{2}_)?/(.*)`)
+	absoluteURLRegex = regexp.MustCompile(`^https?://.*`)
+	// queryReferenceRegex matches quoted query-only references, e.g. `"?a=1"`,
+	// which lxExtractor doesn't.
+	queryReferenceRegex = regexp.MustCompile(`["'](\?[^"'\s<>?]+=[^"'\s<>]*)["']`)
+)
+
+// extractLinks extracts the URLs, and references, found anywhere in body,
+// unwrapping those of archived captures. It is the HTML extractor, and the
+// fallback of the others.
+func extractLinks(base *url.URL, _ string, body []byte) (URLs []string) {
+	for _, lxURL := range lxExtractor.FindAllString(string(body), -1) {
+		lxURL = sources.FixURL(lxURL)
+
+		// `/web/20230128054726/https://example.com/`
+		// `//web.archive.org/web/20230128054726/https://example.com/`
+		// `https://web.archive.org/web/20230128054726/https://example.com/`
+		// `/web/20040111155853js_/http://example.com/2003/mm_menu.js`
+		if match := waybackURLRegex.FindStringSubmatch(lxURL); match != nil {
+			// the original, after the capture's prefix.
+			for _, URL := range mdExtractor.FindAllString(match[1], -1) {
+				// `https://web.archive.org/web/20001110042700/mailto:info@safaricom.co.ke`->safaricom.co.ke
+				if !strings.HasPrefix(URL, "http") {
+					continue
+				}
+
+				URLs = append(URLs, URL)
+			}
+
+			continue
+		}
+
+		// `http://www.safaricom.co.ke/`
+		// `https://web.archive.org/web/*/http://www.safaricom.co.ke/*`
+		// `//html5shim.googlecode.com/svn/trunk/html5.js``
+		if absoluteURLRegex.MatchString(lxURL) || strings.HasPrefix(lxURL, `//`) {
+			if strings.HasPrefix(lxURL, `//`) {
+				var ok bool
+
+				lxURL, ok = resolve(base, lxURL)
+				if !ok {
+					continue
+				}
+			}
+
+			URLs = append(URLs, mdExtractor.FindAllString(lxURL, -1)...)
+
+			continue
+		}
+
+		// `text/javascript`, `image/svg+xml`, but not `img/a.png` or `api/v1`
+		if isMediaType(lxURL) {
+			continue
+		}
+
+		// `//archive.org/includes/analytics.js?v=c535ca67``
+		// `archive.org/components/npm/lit/polyfill-support.js?v=c535ca67`
+		// `archive.org/components/npm/@webcomponents/webcomponentsjs/webcomponents-bundle.js?v=c535ca67`
+		// `archive.org/includes/build/js/ia-topnav.min.js?v=c535ca67`
+		// `archive.org/includes/build/js/archive.min.js?v=c535ca67`
+		// `archive.org/includes/build/css/archive.min.css?v=c535ca67`
+		if strings.Contains(lxURL, "archive.org") {
+			continue
+		}
+
+		// `/api/v1`, `./a`, `../b`
+		if URL, ok := resolve(base, lxURL); ok {
+			URLs = append(URLs, URL)
+		}
+	}
+
+	// `?a=1`
+	for _, match := range queryReferenceRegex.FindAllStringSubmatch(string(body), -1) {
+		if URL, ok := resolve(base, match[1]); ok {
+			URLs = append(URLs, URL)
+		}
+	}
+
+	return
+}
+
+// mediaTopLevelTypes are the registered top-level media types.
+var mediaTopLevelTypes = map[string]bool{
+	"application": true,
+	"audio":       true,
+	"font":        true,
+	"image":       true,
+	"message":     true,
+	"model":       true,
+	"multipart":   true,
+	"text":        true,
+	"video":       true,
+}
+
+// isMediaType tells whether reference is a media type, e.g. the value of a
+// `type` attribute, rather than a relative reference: a `type/subtype` of a
+// registered top-level type, without parameters.
+func isMediaType(reference string) bool {
+	topLevelType, subtype, ok := strings.Cut(reference, "/")
+	if !ok || !mediaTopLevelTypes[strings.ToLower(topLevelType)] {
+		return false
+	}
+
+	mediaType, params, err := mime.ParseMediaType(reference)
+
+	return err == nil && len(params) == 0 && mediaType == strings.ToLower(topLevelType+"/"+subtype)
+}
+
+// resolve resolves a, possibly relative, reference against base.
+func resolve(base *url.URL, reference string) (resolved string, ok bool) {
+	referenceURL, err := url.Parse(reference)
+	if err != nil {
+		return
+	}
+
+	return base.ResolveReference(referenceURL).String(), true
+}
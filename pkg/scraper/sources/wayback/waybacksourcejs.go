@@ -0,0 +1,108 @@
+package wayback
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+// maxJSEndpoints caps the endpoints extracted from a single JavaScript file,
+// guarding against runaway matches on minified bundles.
+const maxJSEndpoints = 1000
+
+// jsEndpointRegex matches, LinkFinder style, quoted or template literal
+// absolute URLs, relative paths and files, e.g. `fetch("/api/v1/users")`.
+var jsEndpointRegex = regexp.MustCompile(`(?:"|'|` + "`" + `)` +
+	`(` +
+	`(?:[a-zA-Z]{1,10}://|//)[^"'/]+\.[a-zA-Z]{2,}[^"']*` + // absolute URLs
+	`|` +
+	`(?:/|\.\./|\./)[^"'><,;| *()(%$^/\\\[\]][^"'><,;|()]+` + // relative paths
+	`|` +
+	`[a-zA-Z0-9_\-/]+/[a-zA-Z0-9_\-/.]+\.(?:[a-zA-Z]{1,4}|action)(?:[\?#][^"']*)?` + // relative paths with extension
+	`|` +
+	`[a-zA-Z0-9_\-/]+/[a-zA-Z0-9_\-/]{3,}(?:[\?#][^"']*)?` + // REST API paths
+	`|` +
+	`[a-zA-Z0-9_\-]+\.(?:php|asp|aspx|jsp|json|action|html|js|txt|xml)(?:[\?#][^"']*)?` + // files
+	`)` +
+	`(?:"|'|` + "`" + `)`)
+
+func isJavaScript(URL string) bool {
+	return sources.MatchExtension(URL, []string{"js", "mjs", "jsx"})
+}
+
+// extractJSEndpoints extracts up to limit endpoints from JavaScript. Bare
+// relative paths, e.g. `api/v1/users`, are rooted, as they mostly refer to
+// the site's API rather than to the script's directory.
+func extractJSEndpoints(content string, limit int) (endpoints []string) {
+	if limit <= 0 {
+		return
+	}
+
+	matches := jsEndpointRegex.FindAllStringSubmatch(content, limit)
+
+	for _, match := range matches {
+		endpoint := match[1]
+
+		// template literals, keep the static prefix only.
+		if index := strings.Index(endpoint, "${"); index >= 0 {
+			endpoint = endpoint[:index]
+		}
+
+		if endpoint == "" || endpoint == "/" {
+			continue
+		}
+
+		if !strings.Contains(endpoint, "://") && !strings.HasPrefix(endpoint, "/") && !strings.HasPrefix(endpoint, ".") {
+			endpoint = "/" + endpoint
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return
+}
+
+// jsExtractor is the JavaScript extractor: the script's endpoints and, as
+// for HTML, the links found anywhere in it, deduplicated and capped, together,
+// at maxJSEndpoints across its chunks.
+type jsExtractor struct {
+	seen map[string]struct{}
+}
+
+func (extractor *jsExtractor) Extract(base *url.URL, contentType string, body []byte) (URLs []string) {
+	if extractor.seen == nil {
+		extractor.seen = map[string]struct{}{}
+	}
+
+	add := func(URL string) {
+		if len(extractor.seen) >= maxJSEndpoints {
+			return
+		}
+
+		if _, ok := extractor.seen[URL]; ok {
+			return
+		}
+
+		extractor.seen[URL] = struct{}{}
+
+		URLs = append(URLs, URL)
+	}
+
+	for _, endpoint := range extractJSEndpoints(string(body), maxJSEndpoints-len(extractor.seen)) {
+		if URL, ok := resolve(base, endpoint); ok {
+			add(URL)
+		}
+	}
+
+	if len(extractor.seen) >= maxJSEndpoints {
+		return
+	}
+
+	for _, URL := range extractLinks(base, contentType, body) {
+		add(URL)
+	}
+
+	return
+}
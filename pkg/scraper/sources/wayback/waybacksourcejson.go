@@ -0,0 +1,135 @@
+package wayback
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/hueristiq/xurlfind3r/pkg/scraper/sources"
+)
+
+const (
+	// maxJSONDepth caps how deep a JSON document is walked, guarding against
+	// pathologically nested ones.
+	maxJSONDepth = 32
+	// maxJSONURLs caps the URLs extracted from a single JSON document.
+	maxJSONURLs = 1000
+)
+
+// isJSON reports whether the snapshot is a JSON document, by its URL's
+// extension or, failing that, its content starting as an object or array.
+func isJSON(URL, content string) bool {
+	if sources.MatchExtension(URL, []string{"json"}) {
+		return true
+	}
+
+	content = strings.TrimSpace(content)
+
+	return strings.HasPrefix(content, "{") || strings.HasPrefix(content, "[")
+}
+
+// jsonExtractor is the JSON extractor: the absolute, or protocol-relative,
+// URLs among the string values of a JSON document, up to maxJSONURLs, decoded
+// as a stream. Content that turns out not to be JSON, e.g. JSONP, is
+// extracted from, from there on, as HTML.
+type jsonExtractor struct{}
+
+func (extractor *jsonExtractor) Extract(base *url.URL, contentType string, body []byte) (URLs []string) {
+	_ = extractor.ExtractStream(base, contentType, bytes.NewReader(body), func(URL string) {
+		URLs = append(URLs, URL)
+	})
+
+	return
+}
+
+// jsonFrame is an object, or array, a JSON token is nested in.
+type jsonFrame struct {
+	// object is whether it's an object and, if so, key whether its next
+	// token is a key.
+	object, key bool
+}
+
+func (extractor *jsonExtractor) ExtractStream(base *url.URL, contentType string, reader io.Reader, found func(URL string)) (err error) {
+	decoder := json.NewDecoder(reader)
+
+	var frames []jsonFrame
+
+	URLs := 0
+
+	for URLs < maxJSONURLs {
+		var token json.Token
+
+		token, err = decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			rest := io.MultiReader(decoder.Buffered(), reader)
+
+			return readChunks(rest, func(chunk []byte) {
+				for _, URL := range extractLinks(base, contentType, chunk) {
+					found(URL)
+				}
+			})
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				frames = append(frames, jsonFrame{object: delim == '{', key: delim == '{'})
+			default:
+				frames = frames[:len(frames)-1]
+
+				if len(frames) > 0 && frames[len(frames)-1].object {
+					frames[len(frames)-1].key = true
+				}
+			}
+
+			continue
+		}
+
+		if len(frames) > 0 && frames[len(frames)-1].object {
+			frame := &frames[len(frames)-1]
+
+			frame.key = !frame.key
+
+			// keys aren't extracted from.
+			if !frame.key {
+				continue
+			}
+		}
+
+		value, ok := token.(string)
+		if !ok || len(frames) > maxJSONDepth {
+			continue
+		}
+
+		if URL, ok := jsonURL(value); ok {
+			found(URL)
+
+			URLs++
+		}
+	}
+
+	return
+}
+
+// jsonURL returns value as a URL, if it's an absolute, or protocol-relative,
+// one.
+func jsonURL(value string) (URL string, ok bool) {
+	value = strings.TrimSpace(value)
+
+	if !strings.HasPrefix(value, "//") && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return
+	}
+
+	URL = sources.AddMissingScheme(value)
+
+	parsedURL, err := url.Parse(URL)
+
+	return URL, err == nil && parsedURL.Host != ""
+}